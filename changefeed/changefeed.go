@@ -0,0 +1,213 @@
+// package changefeed provides methods for writing the differences between two snapshots of the OpenAccess dataset
+// as a stream of machine-readable upsert and delete events.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/diff"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
+	"io"
+	"sync"
+)
+
+// EVENT_UPSERT signals that a record has been added or modified and should be (re)indexed.
+
+const EVENT_UPSERT string = "upsert"
+
+// EVENT_DELETE signals that a record has been removed and should be deleted.
+
+const EVENT_DELETE string = "delete"
+
+// EVENT_COMPLETE is always the last event in a changefeed that was written successfully. A changefeed that does
+// not end with an EVENT_COMPLETE event is incomplete and should not be applied.
+
+const EVENT_COMPLETE string = "complete"
+
+// EVENT_ERROR is the last event in a changefeed that could not be completed. It signals that the events before it
+// are incomplete (in particular that no delete events were written) and should not be applied.
+
+const EVENT_ERROR string = "error"
+
+// Event is a single change event.
+
+type Event struct {
+	// One of the EVENT_UPSERT, EVENT_DELETE, EVENT_COMPLETE or EVENT_ERROR constants.
+	Event string `json:"event"`
+	// One of the diff.CHANGE_ADDED, diff.CHANGE_MODIFIED or diff.CHANGE_REMOVED constants.
+	Change string `json:"change,omitempty"`
+	Id     string `json:"id,omitempty"`
+	Unit   string `json:"unit,omitempty"`
+	// The new body of the record for upsert events.
+	Record json.RawMessage `json:"record,omitempty"`
+	// The reason the changefeed could not be completed for error events.
+	Error string `json:"error,omitempty"`
+}
+
+// Changefeed writes change events, encoded as line-delimited JSON, for the records in a new snapshot relative to
+// the records in an (older) diff.Snapshot. Upsert events are written as records are added and delete events, sorted
+// by `id`, followed by an EVENT_COMPLETE event are written when the Changefeed is closed. Upsert events are streamed
+// so consumers must not apply a changefeed until they have read its EVENT_COMPLETE event.
+
+type Changefeed struct {
+	writer   io.Writer
+	snapshot *diff.Snapshot
+	mu       *sync.Mutex
+}
+
+// NewChangefeed returns a new Changefeed instance that compares records with snapshot and writes events to wr.
+
+func NewChangefeed(wr io.Writer, snapshot *diff.Snapshot) *Changefeed {
+
+	f := &Changefeed{
+		writer:   wr,
+		snapshot: snapshot,
+		mu:       new(sync.Mutex),
+	}
+
+	return f
+}
+
+// Add compares rec with the snapshot and, if it has been added or modified, writes an upsert event.
+
+func (f *Changefeed) Add(ctx context.Context, rec *walk.Record) error {
+
+	change, _ := f.snapshot.Compare(diff.NewEntry(rec))
+
+	if change == diff.CHANGE_UNCHANGED {
+		return nil
+	}
+
+	e := &Event{
+		Event:  EVENT_UPSERT,
+		Change: change,
+		Id:     rec.OpenAccessRecord.Id,
+		Unit:   rec.OpenAccessRecord.UnitCode,
+		Record: json.RawMessage(rec.Body),
+	}
+
+	return f.write(e)
+}
+
+// RecordCallback returns a walk.WalkOpenAccessRecordCallbackFunc that passes each record to Add. Errors are returned as-is.
+
+func (f *Changefeed) RecordCallback() walk.WalkOpenAccessRecordCallbackFunc {
+
+	return func(ctx context.Context, rec *walk.Record, err error) error {
+
+		if err != nil {
+
+			if jw.IsEOFError(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		return f.Add(ctx, rec)
+	}
+}
+
+// Close writes a delete event for each record in the snapshot that has not been passed to Add followed by an
+// EVENT_COMPLETE event.
+
+func (f *Changefeed) Close(ctx context.Context) error {
+
+	for _, entry := range f.snapshot.Unseen() {
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		e := &Event{
+			Event:  EVENT_DELETE,
+			Change: diff.CHANGE_REMOVED,
+			Id:     entry.Id,
+			Unit:   entry.Unit,
+		}
+
+		err := f.write(e)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	e := &Event{
+		Event: EVENT_COMPLETE,
+	}
+
+	return f.write(e)
+}
+
+// Fail writes an EVENT_ERROR event, describing err, signaling that the changefeed is incomplete. It should be
+// called instead of Close.
+
+func (f *Changefeed) Fail(ctx context.Context, err error) error {
+
+	e := &Event{
+		Event: EVENT_ERROR,
+		Error: err.Error(),
+	}
+
+	return f.write(e)
+}
+
+func (f *Changefeed) write(e *Event) error {
+
+	body, err := json.Marshal(e)
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal event for %s, %w", e.Id, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err = f.writer.Write(append(body, '\n'))
+
+	if err != nil {
+		return fmt.Errorf("Failed to write event for %s, %w", e.Id, err)
+	}
+
+	return nil
+}
+
+// WriteChanges indexes old_bucket, according to opts.Old, and then walks new_bucket, according to opts.New, writing
+// change events to wr. The new snapshot is always walked in order (sorted by path and line number) so that the same
+// two snapshots always produce the same events. The opts.Fields property is ignored. If the new snapshot can not be
+// walked an EVENT_ERROR event is written, otherwise the last event written is an EVENT_COMPLETE event.
+
+func WriteChanges(ctx context.Context, wr io.Writer, opts *diff.DiffOptions, old_bucket *blob.Bucket, new_bucket *blob.Bucket) error {
+
+	snapshot, err := diff.IndexSnapshot(ctx, opts.Old, old_bucket)
+
+	if err != nil {
+		return fmt.Errorf("Failed to index old snapshot, %w", err)
+	}
+
+	f := NewChangefeed(wr, snapshot)
+
+	new_opts := *opts.New
+	new_opts.Ordered = true
+	new_opts.RecordCallback = f.RecordCallback()
+
+	err = walk.WalkBucket(ctx, &new_opts, new_bucket)
+
+	if err != nil {
+
+		err = fmt.Errorf("Failed to walk new snapshot, %w", err)
+		fail_err := f.Fail(ctx, err)
+
+		if fail_err != nil {
+			return fmt.Errorf("%w (%v)", err, fail_err)
+		}
+
+		return err
+	}
+
+	return f.Close(ctx)
+}
@@ -0,0 +1,200 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BLOB_CHECKPOINT_WRITE_INTERVAL is the minimum amount of time between writes of a BlobCheckpoint's list of completed keys.
+
+const BLOB_CHECKPOINT_WRITE_INTERVAL time.Duration = 10 * time.Second
+
+// BlobCheckpoint is a Checkpoint implementation that stores completed keys, one per line, in a GoCloud bucket.
+// Since blobs can not be appended to the entire list of completed keys is rewritten each time it is written so,
+// rather than writing it each time a key is marked as complete, it is written at most once every
+// BLOB_CHECKPOINT_WRITE_INTERVAL and when the checkpoint is closed. Keys marked as complete since the last write
+// are lost if the process is interrupted (and will be processed again when the job is resumed).
+
+type BlobCheckpoint struct {
+	Checkpoint
+	bucket     *blob.Bucket
+	key        string
+	completed  map[string]bool
+	dirty      bool
+	last_write time.Time
+	interval   time.Duration
+	mu         *sync.RWMutex
+}
+
+// NewBlobCheckpoint returns a new BlobCheckpoint instance for uri, which is a GoCloud bucket URI whose path is the
+// key of the checkpoint file in that bucket. Any keys that have already been marked as complete are loaded.
+
+func NewBlobCheckpoint(ctx context.Context, uri string) (Checkpoint, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse checkpoint URI, %w", err)
+	}
+
+	key := strings.TrimLeft(u.Path, "/")
+
+	if key == "" {
+		return nil, fmt.Errorf("Checkpoint URI is missing a key")
+	}
+
+	u.Path = ""
+
+	bucket, err := blob.OpenBucket(ctx, u.String())
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checkpoint bucket, %w", err)
+	}
+
+	c, err := newBlobCheckpoint(ctx, bucket, key)
+
+	if err != nil {
+		bucket.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// newBlobCheckpoint returns a new BlobCheckpoint instance for key in bucket, loading any keys that have already
+// been marked as complete. The bucket is closed when the checkpoint is closed.
+
+func newBlobCheckpoint(ctx context.Context, bucket *blob.Bucket, key string) (*BlobCheckpoint, error) {
+
+	c := &BlobCheckpoint{
+		bucket:     bucket,
+		key:        key,
+		completed:  make(map[string]bool),
+		last_write: time.Now(),
+		interval:   BLOB_CHECKPOINT_WRITE_INTERVAL,
+		mu:         new(sync.RWMutex),
+	}
+
+	body, err := bucket.ReadAll(ctx, key)
+
+	if err != nil {
+
+		if gcerrors.Code(err) != gcerrors.NotFound {
+			return nil, fmt.Errorf("Failed to read checkpoint, %w", err)
+		}
+
+		return c, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+
+	for scanner.Scan() {
+
+		k := strings.TrimSpace(scanner.Text())
+
+		if k == "" {
+			continue
+		}
+
+		c.completed[k] = true
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read checkpoint, %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *BlobCheckpoint) IsComplete(ctx context.Context, key string) (bool, error) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.completed[key], nil
+}
+
+func (c *BlobCheckpoint) MarkComplete(ctx context.Context, key string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.completed[key] {
+		return nil
+	}
+
+	c.completed[key] = true
+	c.dirty = true
+
+	if time.Since(c.last_write) < c.interval {
+		return nil
+	}
+
+	return c.write(ctx)
+}
+
+func (c *BlobCheckpoint) Reset(ctx context.Context) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed = make(map[string]bool)
+	return c.write(ctx)
+}
+
+func (c *BlobCheckpoint) Close(ctx context.Context) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dirty {
+
+		err := c.write(ctx)
+
+		if err != nil {
+			c.bucket.Close()
+			return err
+		}
+	}
+
+	return c.bucket.Close()
+}
+
+func (c *BlobCheckpoint) write(ctx context.Context) error {
+
+	keys := make([]string, 0)
+
+	for k := range c.completed {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteString("\n")
+	}
+
+	err := c.bucket.WriteAll(ctx, c.key, buf.Bytes(), nil)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write checkpoint, %w", err)
+	}
+
+	c.dirty = false
+	c.last_write = time.Now()
+	return nil
+}
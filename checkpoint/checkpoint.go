@@ -0,0 +1,43 @@
+// package checkpoint provides methods for recording which files in a bucket have been fully processed so that
+// long-running jobs can be resumed.
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Checkpoint is an interface for recording which files (keys) in a bucket have been fully processed.
+
+type Checkpoint interface {
+	// IsComplete returns a boolean value indicating whether a key has been marked as complete.
+	IsComplete(context.Context, string) (bool, error)
+	// MarkComplete records that a key has been fully processed.
+	MarkComplete(context.Context, string) error
+	// Reset removes all the keys that have been marked as complete.
+	Reset(context.Context) error
+	// Close flushes any pending state and releases any resources held by the checkpoint.
+	Close(context.Context) error
+}
+
+// NewCheckpoint returns a new Checkpoint instance for uri. URIs with a `file://` scheme, or no scheme at all,
+// are treated as paths to a local file. All other URIs are treated as a GoCloud bucket URI whose path is the
+// key of the checkpoint file in that bucket, for example `s3://example-bucket/jobs/emit.txt?region=us-east-1`.
+// Existing state is always loaded; use the Reset method to start over.
+
+func NewCheckpoint(ctx context.Context, uri string) (Checkpoint, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse checkpoint URI, %w", err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		return NewFileCheckpoint(ctx, u.Path)
+	default:
+		return NewBlobCheckpoint(ctx, uri)
+	}
+}
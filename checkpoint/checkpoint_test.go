@@ -0,0 +1,263 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCheckpointResume(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	uri := filepath.Join(dir, "checkpoint.txt")
+
+	open := func() Checkpoint {
+
+		cp, err := NewCheckpoint(ctx, uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint, %v", err)
+		}
+
+		return cp
+	}
+
+	testResume(ctx, t, open)
+}
+
+func TestBlobCheckpointResume(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	open := func() Checkpoint {
+
+		bucket, err := blob.OpenBucket(ctx, fmt.Sprintf("file://%s", dir))
+
+		if err != nil {
+			t.Fatalf("Failed to open bucket, %v", err)
+		}
+
+		cp, err := newBlobCheckpoint(ctx, bucket, "jobs/checkpoint.txt")
+
+		if err != nil {
+			t.Fatalf("Failed to create checkpoint, %v", err)
+		}
+
+		return cp
+	}
+
+	testResume(ctx, t, open)
+}
+
+func TestBlobCheckpointBatchedWrites(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	bucket, err := blob.OpenBucket(ctx, fmt.Sprintf("file://%s", dir))
+
+	if err != nil {
+		t.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	cp, err := newBlobCheckpoint(ctx, bucket, "checkpoint.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint, %v", err)
+	}
+
+	cp.interval = time.Hour
+
+	for i := 0; i < 10; i++ {
+
+		err := cp.MarkComplete(ctx, fmt.Sprintf("metadata/edan/nasm/%02d.txt", i))
+
+		if err != nil {
+			t.Fatalf("Failed to mark key as complete, %v", err)
+		}
+	}
+
+	exists, _ := bucket.Exists(ctx, "checkpoint.txt")
+
+	if exists {
+		t.Fatalf("Expected checkpoint not to be written before the write interval has elapsed")
+	}
+
+	// Once the interval has elapsed the next key triggers a write of all the pending keys
+
+	cp.interval = 0
+
+	err = cp.MarkComplete(ctx, "metadata/edan/nasm/10.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to mark key as complete, %v", err)
+	}
+
+	body, err := bucket.ReadAll(ctx, "checkpoint.txt")
+
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint, %v", err)
+	}
+
+	lines := 0
+
+	for _, b := range body {
+
+		if b == '\n' {
+			lines += 1
+		}
+	}
+
+	if lines != 11 {
+		t.Fatalf("Expected 11 keys in checkpoint but got %d", lines)
+	}
+
+	err = cp.Close(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to close checkpoint, %v", err)
+	}
+}
+
+func TestNewCheckpoint(t *testing.T) {
+
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cp, err := NewCheckpoint(ctx, fmt.Sprintf("file://%s", filepath.Join(dir, "checkpoint.txt")))
+
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint, %v", err)
+	}
+
+	defer cp.Close(ctx)
+
+	_, ok := cp.(*FileCheckpoint)
+
+	if !ok {
+		t.Fatalf("Expected file:// URI to produce a FileCheckpoint")
+	}
+}
+
+// testResume marks keys as complete in a new checkpoint returned by open, reopens it and verifies that the keys
+// are still complete, and then resets it and verifies that they are not.
+
+func testResume(ctx context.Context, t *testing.T, open func() Checkpoint) {
+
+	keys := []string{
+		"metadata/edan/nasm/00.txt",
+		"metadata/edan/chndm/1a.txt",
+	}
+
+	cp := open()
+
+	for _, k := range keys {
+
+		err := cp.MarkComplete(ctx, k)
+
+		if err != nil {
+			t.Fatalf("Failed to mark '%s' as complete, %v", k, err)
+		}
+	}
+
+	// Marking the same key twice is a no-op
+
+	err := cp.MarkComplete(ctx, keys[0])
+
+	if err != nil {
+		t.Fatalf("Failed to mark '%s' as complete, %v", keys[0], err)
+	}
+
+	err = cp.Close(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to close checkpoint, %v", err)
+	}
+
+	cp = open()
+
+	for _, k := range keys {
+
+		complete, err := cp.IsComplete(ctx, k)
+
+		if err != nil {
+			t.Fatalf("Failed to determine whether '%s' is complete, %v", k, err)
+		}
+
+		if !complete {
+			t.Fatalf("Expected '%s' to be complete after resuming", k)
+		}
+	}
+
+	complete, _ := cp.IsComplete(ctx, "metadata/edan/nasm/01.txt")
+
+	if complete {
+		t.Fatalf("Expected unknown key to be incomplete")
+	}
+
+	err = cp.Reset(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to reset checkpoint, %v", err)
+	}
+
+	err = cp.MarkComplete(ctx, keys[1])
+
+	if err != nil {
+		t.Fatalf("Failed to mark '%s' as complete, %v", keys[1], err)
+	}
+
+	cp.Close(ctx)
+
+	cp = open()
+	defer cp.Close(ctx)
+
+	complete, _ = cp.IsComplete(ctx, keys[0])
+
+	if complete {
+		t.Fatalf("Expected '%s' to be incomplete after reset", keys[0])
+	}
+
+	complete, _ = cp.IsComplete(ctx, keys[1])
+
+	if !complete {
+		t.Fatalf("Expected '%s' to be complete after reset", keys[1])
+	}
+}
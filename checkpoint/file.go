@@ -0,0 +1,139 @@
+package checkpoint
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// FileCheckpoint is a Checkpoint implementation that appends completed keys, one per line, to a local file.
+
+type FileCheckpoint struct {
+	Checkpoint
+	path      string
+	fh        *os.File
+	completed map[string]bool
+	mu        *sync.RWMutex
+}
+
+// NewFileCheckpoint returns a new FileCheckpoint instance for path, loading any keys that have already been
+// marked as complete.
+
+func NewFileCheckpoint(ctx context.Context, path string) (Checkpoint, error) {
+
+	if path == "" {
+		return nil, fmt.Errorf("Missing checkpoint path")
+	}
+
+	completed, err := readCompletedFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checkpoint file, %w", err)
+	}
+
+	c := &FileCheckpoint{
+		path:      path,
+		fh:        fh,
+		completed: completed,
+		mu:        new(sync.RWMutex),
+	}
+
+	return c, nil
+}
+
+func (c *FileCheckpoint) IsComplete(ctx context.Context, key string) (bool, error) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.completed[key], nil
+}
+
+func (c *FileCheckpoint) MarkComplete(ctx context.Context, key string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.completed[key] {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(c.fh, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write checkpoint for '%s', %w", key, err)
+	}
+
+	c.completed[key] = true
+	return nil
+}
+
+func (c *FileCheckpoint) Reset(ctx context.Context) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.fh.Truncate(0)
+
+	if err != nil {
+		return fmt.Errorf("Failed to reset checkpoint file, %w", err)
+	}
+
+	c.completed = make(map[string]bool)
+	return nil
+}
+
+func (c *FileCheckpoint) Close(ctx context.Context) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fh.Close()
+}
+
+func readCompletedFile(path string) (map[string]bool, error) {
+
+	completed := make(map[string]bool)
+
+	fh, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checkpoint file, %w", err)
+	}
+
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+
+	for scanner.Scan() {
+
+		key := strings.TrimSpace(scanner.Text())
+
+		if key == "" {
+			continue
+		}
+
+		completed[key] = true
+	}
+
+	err = scanner.Err()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read checkpoint file, %w", err)
+	}
+
+	return completed, nil
+}
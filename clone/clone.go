@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"github.com/aaronland/go-smithsonian-openaccess"
-	"github.com/mholt/archiver/v3"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
+	"github.com/aaronland/go-smithsonian-openaccess/codec"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
 	"gocloud.dev/blob"
 	"io"
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
 type CloneOptions struct {
@@ -16,10 +20,66 @@ type CloneOptions struct {
 	Workers  int
 	Force    bool
 	Compress bool
+	// The name of the registered codec (see the codec package) used to compress files when Compress is true. If empty
+	// then files are compressed using bzip2 encoding.
+	Codec string
+	// An optional checkpoint.Checkpoint instance used to skip files that have already been cloned and to
+	// record the files that are cloned during this run.
+	Checkpoint checkpoint.Checkpoint
+	// The (zero-based) index of the shard to clone when ShardCount is greater than 1. See the shard package for details.
+	ShardIndex int
+	// The total number of shards that files are partitioned in to. If less than or equal to 1 then all files are cloned.
+	ShardCount int
+	// If true then, after cloning, objects in the target bucket (under URI) that no longer have a corresponding object
+	// in the source bucket are removed. See PruneBucket for details.
+	Delete bool
+	// If true then nothing is cloned or removed. When Delete is also true the objects that would be removed are logged.
+	DryRun bool
+	// The number of times to retry cloning a file that failed with a transient error. If 0 files are not retried.
+	Retries int
+	// The delay before the first retry, which doubles for each subsequent retry (with jitter). If 0 then DEFAULT_RETRY_DELAY is used.
+	RetryDelay time.Duration
+	// Zero or more patterns, using the syntax of the path.Match function, of the files to clone. A pattern that matches a
+	// directory matches everything under it. If empty all files are cloned.
+	Include []string
+	// Zero or more patterns, using the syntax of the path.Match function, of the files not to clone. A pattern that matches
+	// a directory matches everything under it.
+	Exclude []string
+	// Zero or more unit codes (for example "nasm") whose metadata files should be cloned. Units are matched using their
+	// metadata prefix in the Smithsonian's bucket (see the units package). If empty all units are cloned.
+	Units []string
+	// The maximum number of bytes per second to read from the source bucket, across all workers. If 0 there is no limit.
+	BytesPerSecond int64
+	// The maximum number of requests per second (listing, reading, writing, comparing or removing files) to make to the
+	// source and target buckets, across all workers. If 0 there is no limit.
+	RequestsPerSecond float64
 }
 
+// CloneBucket clones the files in source_bucket, under opts.URI, to target_bucket. Files that can not be cloned, after
+// retrying, do not stop the clone but are reported in a *CloneErrors error once all the other files have been cloned.
+
 func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bucket, target_bucket *blob.Bucket) error {
 
+	err := shard.Validate(opts.ShardIndex, opts.ShardCount)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = targetCodec(opts)
+
+	if err != nil {
+		return err
+	}
+
+	filter, err := newListFilter(opts)
+
+	if err != nil {
+		return err
+	}
+
+	limiter := newRateLimiter(opts)
+
 	throttle := make(chan bool, opts.Workers)
 
 	for i := 0; i < opts.Workers; i++ {
@@ -29,6 +89,14 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// The keys of all the (sharded) objects in the source bucket, used to determine which objects to
+	// remove from the target bucket when opts.Delete is true
+
+	source_keys := make(map[string]bool)
+
+	failed := make([]*CloneError, 0)
+	failed_mu := new(sync.Mutex)
+
 	var walkFunc func(context.Context, *blob.Bucket, string) error
 
 	walkFunc = func(ctx context.Context, bucket *blob.Bucket, prefix string) error {
@@ -40,7 +108,7 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 			// pass
 		}
 
-		iter := source_bucket.List(&blob.ListOptions{
+		iter := limiter.List(source_bucket, &blob.ListOptions{
 			Delimiter: "/",
 			Prefix:    prefix,
 		})
@@ -68,6 +136,12 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 
 			if obj.IsDir {
 
+				// Excluded directories are never listed
+
+				if !filter.AllowDir(obj.Key) {
+					continue
+				}
+
 				err = walkFunc(ctx, bucket, obj.Key)
 
 				if err != nil {
@@ -77,6 +151,33 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 				continue
 			}
 
+			if !filter.AllowKey(obj.Key) {
+				continue
+			}
+
+			if !shard.Contains(obj.Key, opts.ShardIndex, opts.ShardCount) {
+				continue
+			}
+
+			source_keys[obj.Key] = true
+
+			if opts.DryRun {
+				continue
+			}
+
+			if opts.Checkpoint != nil {
+
+				complete, err := opts.Checkpoint.IsComplete(ctx, obj.Key)
+
+				if err != nil {
+					return fmt.Errorf("Failed to determine checkpoint status for '%s', %w", obj.Key, err)
+				}
+
+				if complete {
+					continue
+				}
+			}
+
 			<-throttle
 
 			wg.Add(1)
@@ -88,10 +189,32 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 					throttle <- true
 				}()
 
-				err = cloneObject(ctx, opts, source_bucket, target_bucket, uri)
+				attempts, err := cloneObjectWithRetries(ctx, opts, limiter, source_bucket, target_bucket, uri)
 
 				if err != nil {
-					log.Printf("Failed to clone '%s', %w", uri, err)
+
+					clone_err := &CloneError{
+						Key:      uri,
+						Attempts: attempts,
+						Err:      err,
+					}
+
+					log.Println(clone_err)
+
+					failed_mu.Lock()
+					failed = append(failed, clone_err)
+					failed_mu.Unlock()
+
+					return
+				}
+
+				if opts.Checkpoint != nil && ctx.Err() == nil {
+
+					err := opts.Checkpoint.MarkComplete(ctx, uri)
+
+					if err != nil {
+						log.Printf("Failed to record checkpoint for '%s', %v", uri, err)
+					}
 				}
 
 			}(obj.Key)
@@ -101,16 +224,70 @@ func CloneBucket(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 		return nil
 	}
 
-	err := walkFunc(ctx, source_bucket, opts.URI)
+	err = walkFunc(ctx, source_bucket, opts.URI)
 
 	if err != nil {
 		return fmt.Errorf("Failed to clone bucket, %w", err)
 	}
 
+	// Don't prune anything unless the source bucket has been listed in its entirety
+
+	if opts.Delete && ctx.Err() == nil {
+
+		_, err = pruneBucket(ctx, opts, limiter, source_keys, target_bucket)
+
+		if err != nil {
+			return fmt.Errorf("Failed to prune bucket, %w", err)
+		}
+	}
+
+	if len(failed) > 0 {
+
+		sort.Slice(failed, func(i, j int) bool {
+			return failed[i].Key < failed[j].Key
+		})
+
+		return &CloneErrors{
+			Errors: failed,
+		}
+	}
+
 	return nil
 }
 
-func cloneObject(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bucket, target_bucket *blob.Bucket, uri string) error {
+// cloneObjectWithRetries calls cloneObject, retrying transient errors according to opts, and returns the number of
+// attempts and the error returned by the last attempt.
+
+func cloneObjectWithRetries(ctx context.Context, opts *CloneOptions, limiter *rateLimiter, source_bucket *blob.Bucket, target_bucket *blob.Bucket, uri string) (int, error) {
+
+	attempts := 0
+
+	for {
+
+		attempts += 1
+
+		err := cloneObject(ctx, opts, limiter, source_bucket, target_bucket, uri)
+
+		if err == nil {
+			return attempts, nil
+		}
+
+		if attempts > opts.Retries || !isRetryable(err) || ctx.Err() != nil {
+			return attempts, err
+		}
+
+		delay := retryDelay(attempts, opts.RetryDelay)
+		log.Printf("Failed to clone '%s' (attempt %d), retrying in %v, %v", uri, attempts, delay, err)
+
+		err = sleep(ctx, delay)
+
+		if err != nil {
+			return attempts, err
+		}
+	}
+}
+
+func cloneObject(ctx context.Context, opts *CloneOptions, limiter *rateLimiter, source_bucket *blob.Bucket, target_bucket *blob.Bucket, uri string) error {
 
 	select {
 	case <-ctx.Done():
@@ -140,10 +317,22 @@ func cloneObject(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 
 	if compare_md5 {
 
+		err := limiter.Request(ctx)
+
+		if err != nil {
+			return err
+		}
+
 		target_attrs, err := target_bucket.Attributes(ctx, uri)
 
 		if err == nil {
 
+			err := limiter.Request(ctx)
+
+			if err != nil {
+				return err
+			}
+
 			source_attrs, err := source_bucket.Attributes(ctx, uri)
 
 			if err != nil {
@@ -156,6 +345,12 @@ func cloneObject(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 		}
 	}
 
+	err := limiter.Request(ctx)
+
+	if err != nil {
+		return err
+	}
+
 	source_fh, err := source_bucket.NewReader(ctx, uri, nil)
 
 	if err != nil {
@@ -164,26 +359,47 @@ func cloneObject(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 
 	defer source_fh.Close()
 
+	c, err := targetCodec(opts)
+
+	if err != nil {
+		return err
+	}
+
 	target_uri := uri
 
-	if opts.Compress {
-		target_uri = fmt.Sprintf("%s.bz2", uri)
+	if c != nil {
+		target_uri = fmt.Sprintf("%s%s", uri, c.Extension())
+	}
+
+	// Cancelling the context passed to NewWriter aborts the write, leaving any existing
+	// file in place, so that a partially written file is never created in the target bucket
+
+	writer_ctx, writer_cancel := context.WithCancel(ctx)
+	defer writer_cancel()
+
+	err = limiter.Request(ctx)
+
+	if err != nil {
+		return err
 	}
 
-	target_fh, err := target_bucket.NewWriter(ctx, target_uri, nil)
+	target_fh, err := target_bucket.NewWriter(writer_ctx, target_uri, nil)
 
 	if err != nil {
 		return err
 	}
 
-	if opts.Compress {
-		arch := archiver.NewBz2()
-		err = arch.Compress(source_fh, target_fh)
+	source_r := limiter.Reader(ctx, source_fh)
+
+	if c != nil {
+		err = c.Compress(source_r, target_fh)
 	} else {
-		_, err = io.Copy(target_fh, source_fh)
+		_, err = io.Copy(target_fh, source_r)
 	}
 
 	if err != nil {
+		writer_cancel()
+		target_fh.Close()
 		return err
 	}
 
@@ -195,3 +411,20 @@ func cloneObject(ctx context.Context, opts *CloneOptions, source_bucket *blob.Bu
 
 	return nil
 }
+
+// targetCodec returns the codec used to compress files in the target bucket or nil if files are not compressed.
+
+func targetCodec(opts *CloneOptions) (codec.Codec, error) {
+
+	if !opts.Compress {
+		return nil, nil
+	}
+
+	name := opts.Codec
+
+	if name == "" {
+		name = codec.BZIP2
+	}
+
+	return codec.NewCodec(name)
+}
@@ -0,0 +1,55 @@
+package clone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The maximum number of keys to include in the string representation of a CloneErrors instance.
+
+const MAX_REPORTED_ERRORS int = 10
+
+// CloneError is a file that could not be cloned.
+
+type CloneError struct {
+	// The key of the file in the source bucket.
+	Key string
+	// The number of times cloning the file was attempted.
+	Attempts int
+	// The error returned by the final attempt.
+	Err error
+}
+
+func (e *CloneError) Error() string {
+	return fmt.Sprintf("Failed to clone '%s' after %d attempt(s), %v", e.Key, e.Attempts, e.Err)
+}
+
+func (e *CloneError) Unwrap() error {
+	return e.Err
+}
+
+// CloneErrors is the aggregate error returned by CloneBucket when one or more files could not be cloned, after
+// retrying, and are missing (or out of date) in the target bucket.
+
+type CloneErrors struct {
+	Errors []*CloneError
+}
+
+func (e *CloneErrors) Error() string {
+
+	count := len(e.Errors)
+
+	keys := make([]string, 0)
+
+	for i, err := range e.Errors {
+
+		if i == MAX_REPORTED_ERRORS {
+			keys = append(keys, fmt.Sprintf("and %d more", count-i))
+			break
+		}
+
+		keys = append(keys, err.Key)
+	}
+
+	return fmt.Sprintf("Failed to clone %d file(s): %s", count, strings.Join(keys, ", "))
+}
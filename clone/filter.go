@@ -0,0 +1,178 @@
+package clone
+
+import (
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/units"
+	"path"
+	"strings"
+)
+
+// listFilter determines which directories are listed, and which files are cloned, according to the Include,
+// Exclude and Units properties of a CloneOptions instance.
+//
+// Patterns use the syntax of the path.Match function and are compared with object keys (for example
+// "metadata/edan/nasm/00.txt"). A pattern that matches a directory (for example "metadata/edan/nasm") matches
+// everything under that directory. Directories that can not contain any matching files are never listed.
+
+type listFilter struct {
+	include []string
+	exclude []string
+	units   []string
+}
+
+func newListFilter(opts *CloneOptions) (*listFilter, error) {
+
+	f := &listFilter{
+		include: make([]string, 0),
+		exclude: make([]string, 0),
+		units:   make([]string, 0),
+	}
+
+	for _, p := range opts.Include {
+
+		p, err := cleanPattern(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f.include = append(f.include, p)
+	}
+
+	for _, p := range opts.Exclude {
+
+		p, err := cleanPattern(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f.exclude = append(f.exclude, p)
+	}
+
+	for _, code := range opts.Units {
+
+		u, err := units.GetUnit(code)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f.units = append(f.units, strings.Trim(u.MetadataPrefix, "/"))
+	}
+
+	return f, nil
+}
+
+// AllowDir returns a boolean value indicating whether dir might contain files that are allowed.
+
+func (f *listFilter) AllowDir(dir string) bool {
+
+	dir = strings.Trim(dir, "/")
+
+	if anyMatch(f.exclude, dir) {
+		return false
+	}
+
+	if len(f.include) > 0 && !anyMayContain(f.include, dir) {
+		return false
+	}
+
+	if len(f.units) > 0 && !anyMayContain(f.units, dir) {
+		return false
+	}
+
+	return true
+}
+
+// AllowKey returns a boolean value indicating whether the file key is allowed.
+
+func (f *listFilter) AllowKey(key string) bool {
+
+	if anyMatch(f.exclude, key) {
+		return false
+	}
+
+	if len(f.include) > 0 && !anyMatch(f.include, key) {
+		return false
+	}
+
+	if len(f.units) > 0 && !anyMatch(f.units, key) {
+		return false
+	}
+
+	return true
+}
+
+func cleanPattern(p string) (string, error) {
+
+	p = strings.Trim(p, "/")
+
+	_, err := path.Match(p, "")
+
+	if err != nil {
+		return "", fmt.Errorf("Invalid pattern '%s', %w", p, err)
+	}
+
+	return p, nil
+}
+
+// anyMatch returns a boolean value indicating whether any of patterns matches key, or one of its parent directories.
+
+func anyMatch(patterns []string, key string) bool {
+
+	segments := strings.Split(key, "/")
+
+	for _, p := range patterns {
+
+		for i := len(segments); i > 0; i-- {
+
+			ok, _ := path.Match(p, strings.Join(segments[0:i], "/"))
+
+			if ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// anyMayContain returns a boolean value indicating whether any of patterns matches dir, one of its parent directories
+// or something that dir might contain.
+
+func anyMayContain(patterns []string, dir string) bool {
+
+	if anyMatch(patterns, dir) {
+		return true
+	}
+
+	dir_segments := strings.Split(dir, "/")
+
+	for _, p := range patterns {
+
+		p_segments := strings.Split(p, "/")
+
+		if len(p_segments) <= len(dir_segments) {
+			continue
+		}
+
+		ok := true
+
+		for i, s := range dir_segments {
+
+			matches, _ := path.Match(p_segments[i], s)
+
+			if !matches {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+package clone
+
+import (
+	"testing"
+)
+
+func TestListFilter(t *testing.T) {
+
+	tests := []struct {
+		opts     *CloneOptions
+		allowed  []string
+		excluded []string
+	}{
+		{
+			opts: &CloneOptions{},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+				"media/images/A.jpg",
+			},
+		},
+		{
+			opts: &CloneOptions{
+				Include: []string{"metadata/edan/*/00.txt"},
+			},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+				"metadata/edan/chndm/00.txt",
+			},
+			excluded: []string{
+				"metadata/edan/nasm/01.txt",
+				"metadata/edan/nasm/index.txt",
+				"media/images/A.jpg",
+			},
+		},
+		{
+			// A pattern that matches a directory matches everything under it
+			opts: &CloneOptions{
+				Include: []string{"/metadata/edan/nasm/"},
+			},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+				"metadata/edan/nasm/index.txt",
+			},
+			excluded: []string{
+				"metadata/edan/chndm/00.txt",
+				"metadata/edan/nasmx/00.txt",
+			},
+		},
+		{
+			opts: &CloneOptions{
+				Exclude: []string{"metadata/edan/*/index.txt", "media"},
+			},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+			},
+			excluded: []string{
+				"metadata/edan/nasm/index.txt",
+				"media/images/A.jpg",
+			},
+		},
+		{
+			opts: &CloneOptions{
+				Units: []string{"nasm", "CHNDM"},
+			},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+				"metadata/edan/chndm/00.txt",
+			},
+			excluded: []string{
+				"metadata/edan/nmah/00.txt",
+				"media/images/A.jpg",
+			},
+		},
+		{
+			// All the filters must be satisfied
+			opts: &CloneOptions{
+				Units:   []string{"nasm", "chndm"},
+				Include: []string{"metadata/edan/*/0*.txt"},
+				Exclude: []string{"metadata/edan/chndm"},
+			},
+			allowed: []string{
+				"metadata/edan/nasm/00.txt",
+			},
+			excluded: []string{
+				"metadata/edan/nasm/1a.txt",
+				"metadata/edan/chndm/00.txt",
+				"metadata/edan/nmah/00.txt",
+			},
+		},
+	}
+
+	for i, test := range tests {
+
+		f, err := newListFilter(test.opts)
+
+		if err != nil {
+			t.Fatalf("Failed to create filter %d, %v", i, err)
+		}
+
+		for _, key := range test.allowed {
+
+			if !f.AllowKey(key) {
+				t.Errorf("Expected filter %d to allow '%s'", i, key)
+			}
+		}
+
+		for _, key := range test.excluded {
+
+			if f.AllowKey(key) {
+				t.Errorf("Expected filter %d to exclude '%s'", i, key)
+			}
+		}
+	}
+}
+
+func TestListFilterAllowDir(t *testing.T) {
+
+	opts := &CloneOptions{
+		Units:   []string{"nasm"},
+		Include: []string{"metadata/edan/*/00.txt"},
+		Exclude: []string{"metadata/edan/nasm/private"},
+	}
+
+	f, err := newListFilter(opts)
+
+	if err != nil {
+		t.Fatalf("Failed to create filter, %v", err)
+	}
+
+	allowed := []string{
+		"metadata/",
+		"metadata/edan/",
+		"metadata/edan/nasm/",
+	}
+
+	excluded := []string{
+		"media/",
+		"metadata/edan/chndm/",
+		"metadata/edan/nasm/private/",
+	}
+
+	for _, dir := range allowed {
+
+		if !f.AllowDir(dir) {
+			t.Errorf("Expected filter to allow directory '%s'", dir)
+		}
+	}
+
+	for _, dir := range excluded {
+
+		if f.AllowDir(dir) {
+			t.Errorf("Expected filter to exclude directory '%s'", dir)
+		}
+	}
+}
+
+func TestListFilterInvalid(t *testing.T) {
+
+	tests := []*CloneOptions{
+		&CloneOptions{Include: []string{"metadata/["}},
+		&CloneOptions{Exclude: []string{"metadata/["}},
+		&CloneOptions{Units: []string{"not-a-unit"}},
+	}
+
+	for i, opts := range tests {
+
+		_, err := newListFilter(opts)
+
+		if err == nil {
+			t.Errorf("Expected options %d to be invalid", i)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package clone
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/codec"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
+	"gocloud.dev/blob"
+	"io"
+	"log"
+	"sort"
+)
+
+// PruneBucket removes the objects in target_bucket, under opts.URI, whose corresponding source key is not present in
+// source_keys and returns their keys, sorted alphabetically. The source key for a target object whose key ends in the
+// extension of a registered codec (for example ".bz2") is the same key without that extension, so that compressed clones
+// are reconciled with their uncompressed source. Only target objects whose source key belongs to the shard defined by
+// opts.ShardIndex and opts.ShardCount, and is allowed by opts.Include, opts.Exclude and opts.Units, are considered. If
+// opts.DryRun is true nothing is removed but the keys that would have been removed are still returned. Requests to
+// target_bucket are limited by opts.RequestsPerSecond.
+
+func PruneBucket(ctx context.Context, opts *CloneOptions, source_keys map[string]bool, target_bucket *blob.Bucket) ([]string, error) {
+
+	limiter := newRateLimiter(opts)
+	return pruneBucket(ctx, opts, limiter, source_keys, target_bucket)
+}
+
+// pruneBucket removes extraneous objects from target_bucket, as described by PruneBucket, making requests no faster than
+// limiter allows. CloneBucket passes its own limiter so that the requests made while pruning count towards the same limit.
+
+func pruneBucket(ctx context.Context, opts *CloneOptions, limiter *rateLimiter, source_keys map[string]bool, target_bucket *blob.Bucket) ([]string, error) {
+
+	filter, err := newListFilter(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	iter := limiter.List(target_bucket, &blob.ListOptions{
+		Prefix: opts.URI,
+	})
+
+	extraneous := make([]string, 0)
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+			// pass
+		}
+
+		obj, err := iter.Next(ctx)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to iterate next, %w", err)
+		}
+
+		if obj.IsDir {
+			continue
+		}
+
+		source_key := SourceKey(obj.Key)
+
+		if !filter.AllowKey(source_key) {
+			continue
+		}
+
+		if !shard.Contains(source_key, opts.ShardIndex, opts.ShardCount) {
+			continue
+		}
+
+		if source_keys[source_key] || source_keys[obj.Key] {
+			continue
+		}
+
+		extraneous = append(extraneous, obj.Key)
+	}
+
+	sort.Strings(extraneous)
+
+	for _, key := range extraneous {
+
+		if opts.DryRun {
+			log.Printf("Would remove '%s' (dry run)", key)
+			continue
+		}
+
+		err := limiter.Request(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = target_bucket.Delete(ctx, key)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to remove '%s', %w", key, err)
+		}
+
+		log.Printf("Removed '%s'", key)
+	}
+
+	return extraneous, nil
+}
+
+// SourceKey returns the key in the source bucket for the object key in a target bucket.
+
+func SourceKey(key string) string {
+	return codec.TrimExtension(key)
+}
@@ -0,0 +1,380 @@
+package clone
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var target_keys = []string{
+	"metadata/edan/chndm/00.txt",
+	"metadata/edan/chndm/01.txt.bz2",
+	"metadata/edan/nasm/00.txt.zst",
+	"metadata/edan/nasm/01.txt",
+	"metadata/edan/nasm/02.txt",
+	"metadata/edan/nasm/index.txt",
+}
+
+// The keys in the source bucket. All the other keys in target_keys are extraneous.
+
+var source_keys = map[string]bool{
+	"metadata/edan/chndm/00.txt": true,
+	"metadata/edan/nasm/00.txt":  true,
+}
+
+// newTargetBucket writes target_keys to a temporary directory and returns a fileblob bucket for it along with the path of
+// the directory, which should be removed once the test is complete.
+
+func newTargetBucket(t *testing.T) (*blob.Bucket, string) {
+
+	dir, err := ioutil.TempDir("", "prune")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	for _, key := range target_keys {
+
+		path := filepath.Join(dir, key)
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory, %v", err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(key), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write file, %v", err)
+		}
+	}
+
+	bucket, err := blob.OpenBucket(context.Background(), fmt.Sprintf("file://%s?metadata=skip", dir))
+
+	if err != nil {
+		t.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	return bucket, dir
+}
+
+func remainingKeys(t *testing.T, bucket *blob.Bucket) []string {
+
+	ctx := context.Background()
+	keys := make([]string, 0)
+
+	iter := bucket.List(nil)
+
+	for {
+
+		obj, err := iter.Next(ctx)
+
+		if err != nil {
+			break
+		}
+
+		keys = append(keys, obj.Key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPruneBucket(t *testing.T) {
+
+	ctx := context.Background()
+
+	expected := []string{
+		"metadata/edan/chndm/01.txt.bz2",
+		"metadata/edan/nasm/01.txt",
+		"metadata/edan/nasm/02.txt",
+		"metadata/edan/nasm/index.txt",
+	}
+
+	for _, dry_run := range []bool{true, false} {
+
+		bucket, dir := newTargetBucket(t)
+		defer os.RemoveAll(dir)
+		defer bucket.Close()
+
+		opts := &CloneOptions{
+			URI:    "metadata/edan/",
+			DryRun: dry_run,
+		}
+
+		removed, err := PruneBucket(ctx, opts, source_keys, bucket)
+
+		if err != nil {
+			t.Fatalf("Failed to prune bucket, %v", err)
+		}
+
+		if strings.Join(removed, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected %v to be removed but got %v (dry run: %t)", expected, removed, dry_run)
+		}
+
+		remaining := remainingKeys(t, bucket)
+
+		if dry_run {
+
+			if len(remaining) != len(target_keys) {
+				t.Fatalf("Expected dry run not to remove anything but %d keys remain", len(remaining))
+			}
+
+			continue
+		}
+
+		if strings.Join(remaining, ",") != "metadata/edan/chndm/00.txt,metadata/edan/nasm/00.txt.zst" {
+			t.Fatalf("Unexpected keys after pruning, %v", remaining)
+		}
+	}
+}
+
+func TestPruneBucketScope(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, dir := newTargetBucket(t)
+	defer os.RemoveAll(dir)
+	defer bucket.Close()
+
+	// Only keys allowed by the filters (and the URI) are considered
+
+	opts := &CloneOptions{
+		URI:     "metadata/edan/nasm/",
+		Exclude: []string{"metadata/edan/*/index.txt"},
+		DryRun:  true,
+	}
+
+	removed, err := PruneBucket(ctx, opts, source_keys, bucket)
+
+	if err != nil {
+		t.Fatalf("Failed to prune bucket, %v", err)
+	}
+
+	if strings.Join(removed, ",") != "metadata/edan/nasm/01.txt,metadata/edan/nasm/02.txt" {
+		t.Fatalf("Unexpected keys removed with filters, %v", removed)
+	}
+
+	opts = &CloneOptions{
+		URI:    "metadata/edan/",
+		Units:  []string{"chndm"},
+		DryRun: true,
+	}
+
+	removed, err = PruneBucket(ctx, opts, source_keys, bucket)
+
+	if err != nil {
+		t.Fatalf("Failed to prune bucket, %v", err)
+	}
+
+	if strings.Join(removed, ",") != "metadata/edan/chndm/01.txt.bz2" {
+		t.Fatalf("Unexpected keys removed with units, %v", removed)
+	}
+
+	// Every extraneous key is removed by exactly one shard and, for each shard, only keys (without their codec
+	// extension) that belong to that shard are removed
+
+	seen := make(map[string]int)
+
+	for i := 0; i < 3; i++ {
+
+		opts := &CloneOptions{
+			URI:        "metadata/edan/",
+			ShardIndex: i,
+			ShardCount: 3,
+			DryRun:     true,
+		}
+
+		removed, err := PruneBucket(ctx, opts, source_keys, bucket)
+
+		if err != nil {
+			t.Fatalf("Failed to prune shard %d, %v", i, err)
+		}
+
+		for _, key := range removed {
+
+			if !shard.Contains(SourceKey(key), i, 3) {
+				t.Fatalf("Shard %d removed key '%s' from another shard", i, key)
+			}
+
+			seen[key] += 1
+		}
+	}
+
+	if len(seen) != 4 {
+		t.Fatalf("Expected 4 keys to be removed across all shards but got %d", len(seen))
+	}
+
+	for key, count := range seen {
+
+		if count != 1 {
+			t.Fatalf("Expected '%s' to be removed by exactly one shard but was removed by %d", key, count)
+		}
+	}
+}
+
+func TestPruneBucketRequests(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, dir := newTargetBucket(t)
+	defer os.RemoveAll(dir)
+	defer bucket.Close()
+
+	opts := &CloneOptions{
+		URI: "metadata/edan/nasm/",
+	}
+
+	// The rate is low enough that no tokens are added during the test so the tokens remaining are the
+	// burst size minus the number of requests made
+
+	limiter := &rateLimiter{
+		requests: NewTokenBucket(0.001, 10),
+	}
+
+	removed, err := pruneBucket(ctx, opts, limiter, source_keys, bucket)
+
+	if err != nil {
+		t.Fatalf("Failed to prune bucket, %v", err)
+	}
+
+	// One page of results plus one request for each key that was removed
+
+	expected := 1 + len(removed)
+	requests := int(10 - limiter.requests.tokens + 0.5)
+
+	if requests != expected {
+		t.Fatalf("Expected %d requests but got %d", expected, requests)
+	}
+}
+
+func TestLimitedListIterator(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, dir := newTargetBucket(t)
+	defer os.RemoveAll(dir)
+	defer bucket.Close()
+
+	limiter := &rateLimiter{
+		requests: NewTokenBucket(0.001, 10),
+	}
+
+	iter := limiter.List(bucket, &blob.ListOptions{
+		Prefix: "metadata/edan/nasm/",
+	})
+
+	count := 0
+
+	for {
+
+		_, err := iter.Next(ctx)
+
+		if err != nil {
+			break
+		}
+
+		count += 1
+	}
+
+	if count != 4 {
+		t.Fatalf("Expected 4 objects but got %d", count)
+	}
+
+	requests := int(10 - limiter.requests.tokens + 0.5)
+
+	if requests != 1 {
+		t.Fatalf("Expected listing to be 1 request but got %d", requests)
+	}
+}
+
+func TestIsRetryableBucketError(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, dir := newTargetBucket(t)
+	defer os.RemoveAll(dir)
+	defer bucket.Close()
+
+	_, err := bucket.NewReader(ctx, "metadata/edan/missing.txt", nil)
+
+	if err == nil {
+		t.Fatalf("Expected missing key to fail")
+	}
+
+	if isRetryable(err) {
+		t.Fatalf("Expected missing key not to be retryable, %v", err)
+	}
+}
+
+func TestCloneBucketDelete(t *testing.T) {
+
+	ctx := context.Background()
+
+	target_bucket, target_dir := newTargetBucket(t)
+	defer os.RemoveAll(target_dir)
+	defer target_bucket.Close()
+
+	source_dir, err := ioutil.TempDir("", "source")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(source_dir)
+
+	for key := range source_keys {
+
+		path := filepath.Join(source_dir, key)
+
+		os.MkdirAll(filepath.Dir(path), 0755)
+
+		err := ioutil.WriteFile(path, []byte(key), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write file, %v", err)
+		}
+	}
+
+	source_bucket, err := blob.OpenBucket(ctx, fmt.Sprintf("file://%s?metadata=skip", source_dir))
+
+	if err != nil {
+		t.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	defer source_bucket.Close()
+
+	// Cloning and pruning share the same request limit
+
+	opts := &CloneOptions{
+		URI:               "metadata/edan/",
+		Workers:           2,
+		Delete:            true,
+		RequestsPerSecond: 1000,
+	}
+
+	err = CloneBucket(ctx, opts, source_bucket, target_bucket)
+
+	if err != nil {
+		t.Fatalf("Failed to clone bucket, %v", err)
+	}
+
+	remaining := remainingKeys(t, target_bucket)
+
+	// nasm/00.txt.zst is a compressed copy of nasm/00.txt so it is kept
+
+	expected := "metadata/edan/chndm/00.txt,metadata/edan/nasm/00.txt,metadata/edan/nasm/00.txt.zst"
+
+	if strings.Join(remaining, ",") != expected {
+		t.Fatalf("Unexpected keys after cloning, %v", remaining)
+	}
+}
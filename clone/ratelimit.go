@@ -0,0 +1,207 @@
+package clone
+
+import (
+	"context"
+	"gocloud.dev/blob"
+	"io"
+	"sync"
+	"time"
+)
+
+// LIST_PAGE_SIZE is the maximum number of objects requested from a bucket in a single list request.
+
+const LIST_PAGE_SIZE int = 1000
+
+// TokenBucket is a token bucket rate limiter. Tokens are added at a fixed rate, up to a maximum (the burst size),
+// and each operation removes one or more tokens. When there aren't enough tokens callers wait until there are.
+// Operations that need more tokens than the burst size are allowed but the caller waits as though the tokens had
+// been added at the fixed rate. A nil *TokenBucket does not limit anything.
+
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     *sync.Mutex
+}
+
+// NewTokenBucket returns a new TokenBucket that adds rate tokens per second, up to a maximum of burst tokens. The
+// bucket starts full. If rate is less than or equal to 0 then nil is returned.
+
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	b := &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		mu:     new(sync.Mutex),
+	}
+
+	return b
+}
+
+// Wait removes n tokens from b, waiting until they are available or ctx is cancelled.
+
+func (b *TokenBucket) Wait(ctx context.Context, n int) error {
+
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+
+	now := time.Now()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	// Take the tokens now, going in to debt if necessary, so that concurrent callers are
+	// served in the order they arrived
+
+	b.tokens -= float64(n)
+	debt := -b.tokens
+
+	b.mu.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+
+	delay := time.Duration(debt / b.rate * float64(time.Second))
+	return sleep(ctx, delay)
+}
+
+// rateLimiter applies the BytesPerSecond and RequestsPerSecond limits of a CloneOptions instance.
+
+type rateLimiter struct {
+	bytes    *TokenBucket
+	requests *TokenBucket
+}
+
+func newRateLimiter(opts *CloneOptions) *rateLimiter {
+
+	l := &rateLimiter{
+		bytes:    NewTokenBucket(float64(opts.BytesPerSecond), float64(opts.BytesPerSecond)),
+		requests: NewTokenBucket(opts.RequestsPerSecond, opts.RequestsPerSecond),
+	}
+
+	return l
+}
+
+// Request waits until another request to a bucket is allowed.
+
+func (l *rateLimiter) Request(ctx context.Context) error {
+	return l.requests.Wait(ctx, 1)
+}
+
+// Reader returns an io.Reader that reads from r no faster than the bytes per second limit.
+
+func (l *rateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+
+	if l.bytes == nil {
+		return r
+	}
+
+	lr := &limitedReader{
+		ctx:    ctx,
+		reader: r,
+		bucket: l.bytes,
+	}
+
+	return lr
+}
+
+// List returns an iterator for the objects in bucket matching opts that waits until another request is allowed
+// before requesting each page of results.
+
+func (l *rateLimiter) List(bucket *blob.Bucket, opts *blob.ListOptions) *limitedListIterator {
+
+	iter := &limitedListIterator{
+		limiter: l,
+		bucket:  bucket,
+		opts:    opts,
+		token:   blob.FirstPageToken,
+		page:    make([]*blob.ListObject, 0),
+	}
+
+	return iter
+}
+
+// limitedListIterator is a rate limited equivalent of blob.ListIterator. Each page of results is one request.
+
+type limitedListIterator struct {
+	limiter *rateLimiter
+	bucket  *blob.Bucket
+	opts    *blob.ListOptions
+	token   []byte
+	page    []*blob.ListObject
+	idx     int
+}
+
+// Next returns the next object in the listing or io.EOF if there are no more objects.
+
+func (i *limitedListIterator) Next(ctx context.Context) (*blob.ListObject, error) {
+
+	for i.idx >= len(i.page) {
+
+		if len(i.token) == 0 {
+			return nil, io.EOF
+		}
+
+		err := i.limiter.Request(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		page, token, err := i.bucket.ListPage(ctx, i.token, LIST_PAGE_SIZE, i.opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		i.page = page
+		i.token = token
+		i.idx = 0
+	}
+
+	obj := i.page[i.idx]
+	i.idx += 1
+
+	return obj, nil
+}
+
+type limitedReader struct {
+	ctx    context.Context
+	reader io.Reader
+	bucket *TokenBucket
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+
+	n, err := r.reader.Read(p)
+
+	if n > 0 {
+
+		wait_err := r.bucket.Wait(r.ctx, n)
+
+		if wait_err != nil {
+			return n, wait_err
+		}
+	}
+
+	return n, err
+}
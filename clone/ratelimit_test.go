@@ -0,0 +1,145 @@
+package clone
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketNil(t *testing.T) {
+
+	b := NewTokenBucket(0, 10)
+
+	if b != nil {
+		t.Fatalf("Expected a rate of 0 to produce a nil token bucket")
+	}
+
+	err := b.Wait(context.Background(), 100)
+
+	if err != nil {
+		t.Fatalf("Expected nil token bucket not to limit anything, %v", err)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+
+	ctx := context.Background()
+
+	// 100 tokens per second with a burst of 10 tokens
+
+	b := NewTokenBucket(100, 10)
+
+	t1 := time.Now()
+
+	err := b.Wait(ctx, 10)
+
+	if err != nil {
+		t.Fatalf("Failed to wait, %v", err)
+	}
+
+	if time.Since(t1) > 5*time.Millisecond {
+		t.Fatalf("Expected burst not to wait")
+	}
+
+	// The bucket is now empty so the next 10 tokens take 100ms
+
+	t1 = time.Now()
+
+	err = b.Wait(ctx, 10)
+
+	if err != nil {
+		t.Fatalf("Failed to wait, %v", err)
+	}
+
+	elapsed := time.Since(t1)
+
+	if elapsed < 80*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected to wait about 100ms but waited %v", elapsed)
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+
+	b := NewTokenBucket(1, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Wait(ctx, 100)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected wait to be cancelled but got %v", err)
+	}
+}
+
+func TestTokenBucketShared(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Callers share the same tokens so two callers taking 5 tokens each wait as long as one caller taking 10
+
+	b := NewTokenBucket(100, 1)
+	b.Wait(ctx, 1)
+
+	t1 := time.Now()
+
+	done_ch := make(chan bool)
+
+	for i := 0; i < 2; i++ {
+
+		go func() {
+			b.Wait(ctx, 5)
+			done_ch <- true
+		}()
+	}
+
+	<-done_ch
+	<-done_ch
+
+	elapsed := time.Since(t1)
+
+	if elapsed < 80*time.Millisecond {
+		t.Fatalf("Expected concurrent callers to wait about 100ms but waited %v", elapsed)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+
+	ctx := context.Background()
+
+	opts := &CloneOptions{
+		BytesPerSecond: 1000,
+	}
+
+	l := newRateLimiter(opts)
+
+	body := bytes.Repeat([]byte("x"), 1100)
+
+	t1 := time.Now()
+
+	out, err := ioutil.ReadAll(l.Reader(ctx, bytes.NewReader(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to read, %v", err)
+	}
+
+	if !bytes.Equal(out, body) {
+		t.Fatalf("Unexpected output from rate limited reader")
+	}
+
+	// The first 1000 bytes are the burst and the remaining 100 bytes take 100ms
+
+	elapsed := time.Since(t1)
+
+	if elapsed < 80*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected to wait about 100ms but waited %v", elapsed)
+	}
+
+	r := bytes.NewReader(body)
+
+	if newRateLimiter(&CloneOptions{}).Reader(ctx, r) != r {
+		t.Fatalf("Expected reader not to be wrapped when there is no limit")
+	}
+}
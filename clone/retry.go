@@ -0,0 +1,86 @@
+package clone
+
+import (
+	"context"
+	"errors"
+	"gocloud.dev/gcerrors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DEFAULT_RETRIES is the default number of times to retry cloning a file that failed with a transient error.
+
+const DEFAULT_RETRIES int = 3
+
+// DEFAULT_RETRY_DELAY is the default delay before the first retry. The delay doubles for each subsequent retry.
+
+const DEFAULT_RETRY_DELAY time.Duration = 1 * time.Second
+
+// MAX_RETRY_DELAY is the maximum delay between retries.
+
+const MAX_RETRY_DELAY time.Duration = 1 * time.Minute
+
+var jitter = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+var jitter_mu = new(sync.Mutex)
+
+// retryDelay returns the delay before retry number attempt (starting at 1) using exponential backoff, starting at
+// base and capped at MAX_RETRY_DELAY, with "equal jitter" so that workers which failed at the same time don't all
+// retry at the same time.
+
+func retryDelay(attempt int, base time.Duration) time.Duration {
+
+	if base <= 0 {
+		base = DEFAULT_RETRY_DELAY
+	}
+
+	d := base
+
+	for i := 1; i < attempt && d < MAX_RETRY_DELAY; i++ {
+		d = d * 2
+	}
+
+	if d > MAX_RETRY_DELAY {
+		d = MAX_RETRY_DELAY
+	}
+
+	half := int64(d / 2)
+
+	jitter_mu.Lock()
+	defer jitter_mu.Unlock()
+
+	return time.Duration(half + jitter.Int63n(half+1))
+}
+
+// isRetryable returns a boolean value indicating whether err might succeed if tried again. Errors that will
+// not change by retrying, like missing files or insufficient permissions, are not retried.
+
+func isRetryable(err error) bool {
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound, gcerrors.PermissionDenied, gcerrors.InvalidArgument, gcerrors.FailedPrecondition, gcerrors.Unimplemented, gcerrors.Canceled:
+		return false
+	default:
+		return true
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, in which case it returns ctx.Err().
+
+func sleep(ctx context.Context, d time.Duration) error {
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
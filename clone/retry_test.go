@@ -0,0 +1,83 @@
+package clone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+
+	base := 100 * time.Millisecond
+
+	for attempt := 1; attempt <= 12; attempt++ {
+
+		expected := base << uint(attempt-1)
+
+		if expected > MAX_RETRY_DELAY {
+			expected = MAX_RETRY_DELAY
+		}
+
+		for i := 0; i < 20; i++ {
+
+			d := retryDelay(attempt, base)
+
+			if d < expected/2 || d > expected {
+				t.Fatalf("Expected delay for attempt %d to be between %v and %v but got %v", attempt, expected/2, expected, d)
+			}
+		}
+	}
+
+	d := retryDelay(1, 0)
+
+	if d < DEFAULT_RETRY_DELAY/2 || d > DEFAULT_RETRY_DELAY {
+		t.Fatalf("Expected default delay to be between %v and %v but got %v", DEFAULT_RETRY_DELAY/2, DEFAULT_RETRY_DELAY, d)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+
+	retryable := []error{
+		errors.New("connection reset by peer"),
+		context.DeadlineExceeded,
+	}
+
+	not_retryable := []error{
+		context.Canceled,
+		fmt.Errorf("Failed to clone, %w", context.Canceled),
+	}
+
+	for _, err := range retryable {
+
+		if !isRetryable(err) {
+			t.Errorf("Expected '%v' to be retryable", err)
+		}
+	}
+
+	for _, err := range not_retryable {
+
+		if isRetryable(err) {
+			t.Errorf("Expected '%v' not to be retryable", err)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+
+	err := sleep(context.Background(), time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("Failed to sleep, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = sleep(ctx, time.Hour)
+
+	if err != context.Canceled {
+		t.Fatalf("Expected sleep to be cancelled but got %v", err)
+	}
+}
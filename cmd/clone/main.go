@@ -2,17 +2,108 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
 	"github.com/aaronland/go-smithsonian-openaccess/clone"
+	"github.com/aaronland/go-smithsonian-openaccess/codec"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
+	"github.com/aaronland/go-smithsonian-openaccess/units"
 	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/s3blob"
 	"log"
 	"os"
+	"path"
+	"strings"
 )
 
+// stringFlags is a flag.Value for flags that may be specified multiple times.
+
+type stringFlags []string
+
+func (f *stringFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// compressFlag is a flag.Value for the -compress flag. It takes the name of a registered codec but may also be
+// passed without a value, in which case files are compressed using bzip2 encoding.
+
+type compressFlag struct {
+	codec string
+}
+
+func (f *compressFlag) String() string {
+	return f.codec
+}
+
+func (f *compressFlag) Set(value string) error {
+
+	switch value {
+	case "true":
+		f.codec = codec.BZIP2
+		return nil
+	case "false":
+		f.codec = ""
+		return nil
+	default:
+		// pass
+	}
+
+	_, err := codec.NewCodec(value)
+
+	if err != nil {
+		return err
+	}
+
+	f.codec = value
+	return nil
+}
+
+func (f *compressFlag) IsBoolFlag() bool {
+	return true
+}
+
+// compressArgs returns a copy of args where "-compress {CODEC}" is replaced by "-compress={CODEC}". This is necessary
+// because the flag package never assigns the following argument to a flag that may be passed without a value.
+
+func compressArgs(args []string) []string {
+
+	rewritten := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+
+		arg := args[i]
+
+		if arg == "--" {
+			rewritten = append(rewritten, args[i:]...)
+			break
+		}
+
+		if (arg == "-compress" || arg == "--compress") && i+1 < len(args) {
+
+			_, err := codec.NewCodec(args[i+1])
+
+			if err == nil {
+				rewritten = append(rewritten, fmt.Sprintf("%s=%s", arg, args[i+1]))
+				i += 1
+				continue
+			}
+		}
+
+		rewritten = append(rewritten, arg)
+	}
+
+	return rewritten
+}
+
 func main() {
 
 	source_bucket_uri := flag.String("source-bucket-uri", "si://", "A valid GoCloud bucket URI. Valid schemes are: file://, s3:// and si:// which is signals that data should be retrieved from the Smithsonian's 'smithsonian-open-access' S3 bucket.")
@@ -21,7 +112,34 @@ func main() {
 
 	workers := flag.Int("workers", 10, "The maximum number of concurrent workers. This is used to prevent filehandle exhaustion.")
 	force := flag.Bool("force", false, "Clone files even if they are present in target bucket and MD5 hashes between source and target buckets match.")
-	compress := flag.Bool("compress", false, "Compress files in the target bucket using bzip2 encoding. Files will be appended with a '.bz2' suffix.")
+	valid_codecs := strings.Join(codec.Codecs(), ", ")
+	desc_compress := fmt.Sprintf("Compress files in the target bucket using this codec. If passed without a value files are compressed using bzip2 encoding. Files will be appended with the codec's suffix (for example '.bz2'). Valid codecs are: %s", valid_codecs)
+
+	var compress compressFlag
+	flag.Var(&compress, "compress", desc_compress)
+
+	checkpoint_uri := flag.String("checkpoint", "", "An optional checkpoint URI used to record which files have been cloned. Valid URIs are a local path (or file:// URI) or a GoCloud bucket URI whose path is the key of the checkpoint file.")
+	resume := flag.Bool("resume", false, "Skip files that have already been recorded as cloned in the -checkpoint URI. If false any existing checkpoint data is discarded.")
+
+	bytes_per_second := flag.Int64("bytes-per-second", 0, "The maximum number of bytes per second to read from the source bucket, across all workers. If 0 there is no limit.")
+	requests_per_second := flag.Float64("requests-per-second", 0, "The maximum number of requests per second to make to the source and target buckets, across all workers. If 0 there is no limit.")
+
+	retries := flag.Int("retries", clone.DEFAULT_RETRIES, "The number of times to retry cloning a file that failed with a transient error.")
+	retry_delay := flag.Duration("retry-delay", clone.DEFAULT_RETRY_DELAY, "The delay before retrying a file for the first time. The delay doubles (with some random jitter) for each subsequent retry.")
+
+	delete_extraneous := flag.Bool("delete", false, "Remove files in the target bucket that no longer exist in the source bucket, after cloning. Files with a compression suffix (for example '.bz2') are compared with the source file without that suffix.")
+	dry_run := flag.Bool("dry-run", false, "Do not clone or remove any files. If -delete is true the files that would be removed are logged.")
+
+	var include stringFlags
+	flag.Var(&include, "include", "One or more path.Match patterns of the files to clone, for example 'metadata/edan/*/00.txt'. A pattern that matches a directory matches everything under it. If absent all files are cloned.")
+
+	var exclude stringFlags
+	flag.Var(&exclude, "exclude", "One or more path.Match patterns of the files not to clone. A pattern that matches a directory matches everything under it. Excluded directories are never listed.")
+
+	unit_codes := flag.String("unit", "", "An optional comma-separated list of unit codes (for example 'nasm,chndm') whose metadata files should be cloned. Units are matched using their metadata prefix in the Smithsonian's bucket, for example 'metadata/edan/nasm'.")
+
+	shard_index := flag.Int("shard", 0, "The (zero-based) index of the shard to process when the -shards flag is greater than 1.")
+	shard_count := flag.Int("shards", 1, "The total number of shards to partition files in to. Each shard is assigned a deterministic subset of files, by unit and hash prefix, so that work can be distributed across multiple processes.")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage:\n")
@@ -30,7 +148,41 @@ func main() {
 		flag.PrintDefaults()
 	}
 
-	flag.Parse()
+	flag.CommandLine.Parse(compressArgs(os.Args[1:]))
+
+	err := shard.Validate(*shard_index, *shard_count)
+
+	if err != nil {
+		log.Fatalf("Invalid -shard or -shards value, %v", err)
+	}
+
+	for _, p := range append(include, exclude...) {
+
+		_, err := path.Match(p, "")
+
+		if err != nil {
+			log.Fatalf("Invalid -include or -exclude pattern '%s', %v", p, err)
+		}
+	}
+
+	selected_units := make([]string, 0)
+
+	for _, code := range strings.Split(*unit_codes, ",") {
+
+		code = strings.TrimSpace(code)
+
+		if code == "" {
+			continue
+		}
+
+		_, err := units.GetUnit(code)
+
+		if err != nil {
+			log.Fatalf("Invalid -unit value, %v", err)
+		}
+
+		selected_units = append(selected_units, code)
+	}
 
 	ctx := context.Background()
 
@@ -50,25 +202,110 @@ func main() {
 
 	defer target_bucket.Close()
 
+	var cp checkpoint.Checkpoint
+
+	if *checkpoint_uri != "" {
+
+		c, err := checkpoint.NewCheckpoint(ctx, *checkpoint_uri)
+
+		if err != nil {
+			log.Fatalf("Failed to create checkpoint, %v", err)
+		}
+
+		if !*resume {
+
+			err := c.Reset(ctx)
+
+			if err != nil {
+				log.Fatalf("Failed to reset checkpoint, %v", err)
+			}
+		}
+
+		cp = c
+
+	} else if *resume {
+		log.Fatal("-resume requires a -checkpoint URI")
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	uris := flag.Args()
 
+	// Only default to the root of the source bucket when the -include or -unit flags narrow the set
+	// of files to clone. Otherwise a bare invocation would clone the entire bucket.
+
+	if len(uris) == 0 && (len(include) > 0 || len(selected_units) > 0) {
+		uris = []string{""}
+	}
+
+	// Checkpoints may buffer the keys that have been marked as complete so they are closed explicitly, rather
+	// than deferred, because deferred functions aren't run when exiting with log.Fatal or os.Exit
+
+	close_checkpoint := func() {
+
+		if cp == nil {
+			return
+		}
+
+		err := cp.Close(ctx)
+
+		if err != nil {
+			log.Fatalf("Failed to close checkpoint, %v", err)
+		}
+	}
+
+	failed := make([]*clone.CloneError, 0)
+
 	for _, uri := range uris {
 
 		opts := &clone.CloneOptions{
-			URI:      uri,
-			Workers:  *workers,
-			Force:    *force,
-			Compress: *compress,
+			URI:        uri,
+			Workers:    *workers,
+			Force:      *force,
+			Compress:   compress.codec != "",
+			Codec:      compress.codec,
+			Checkpoint: cp,
+			ShardIndex: *shard_index,
+			ShardCount: *shard_count,
+			Delete:     *delete_extraneous,
+			DryRun:     *dry_run,
+			Retries:    *retries,
+			RetryDelay: *retry_delay,
+			Include:    include,
+			Exclude:    exclude,
+			Units:      selected_units,
+
+			BytesPerSecond:    *bytes_per_second,
+			RequestsPerSecond: *requests_per_second,
 		}
 
 		err := clone.CloneBucket(ctx, opts, source_bucket, target_bucket)
 
 		if err != nil {
-			log.Fatalf("Failed to clone %s, %v", uri, err)
+
+			var clone_errs *clone.CloneErrors
+
+			if !errors.As(err, &clone_errs) {
+				close_checkpoint()
+				log.Fatalf("Failed to clone %s, %v", uri, err)
+			}
+
+			failed = append(failed, clone_errs.Errors...)
 		}
 	}
 
+	close_checkpoint()
+
+	if len(failed) > 0 {
+
+		log.Printf("Failed to clone %d file(s):", len(failed))
+
+		for _, e := range failed {
+			log.Printf("  %s (%d attempt(s)), %v", e.Key, e.Attempts, e.Err)
+		}
+
+		os.Exit(1)
+	}
+
 }
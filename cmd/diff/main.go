@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-json-query"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/diff"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/s3blob"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+
+	old_bucket_uri := flag.String("old-bucket-uri", "", "A valid GoCloud bucket URI for the old (previous) snapshot. Valid schemes are: file://, s3:// and si:// which is signals that data should be retrieved from the Smithsonian's 'smithsonian-open-access' S3 bucket.")
+	new_bucket_uri := flag.String("new-bucket-uri", "si://", "A valid GoCloud bucket URI for the new snapshot. Valid schemes are: file://, s3:// and si:// which is signals that data should be retrieved from the Smithsonian's 'smithsonian-open-access' S3 bucket.")
+	workers := flag.Int("workers", 10, "The maximum number of concurrent workers. This is used to prevent filehandle exhaustion.")
+
+	fields := flag.Bool("fields", false, "Include field-level differences for modified records. This requires the old snapshot to be walked twice.")
+
+	valid_formats := strings.Join([]string{"jsonl", "csv"}, ", ")
+	desc_formats := fmt.Sprintf("The format to emit changes in. Valid formats are: %s", valid_formats)
+
+	format := flag.String("format", "jsonl", desc_formats)
+	csv_header := flag.Bool("csv-header", true, "Include a CSV header row in the output")
+
+	to_stdout := flag.Bool("stdout", true, "Emit to STDOUT")
+	to_devnull := flag.Bool("null", false, "Emit to /dev/null")
+
+	var queries query.QueryFlags
+	flag.Var(&queries, "query", "One or more {PATH}={REGEXP} parameters for filtering records.")
+
+	valid_modes := strings.Join([]string{query.QUERYSET_MODE_ALL, query.QUERYSET_MODE_ANY}, ", ")
+	desc_modes := fmt.Sprintf("Specify how query filtering should be evaluated. Valid modes are: %s", valid_modes)
+
+	query_mode := flag.String("query-mode", query.QUERYSET_MODE_ALL, desc_modes)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [options] [path1 path2 ... pathN]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	switch *format {
+	case "jsonl", "csv":
+		// pass
+	default:
+		log.Fatalf("Invalid -format value '%s'", *format)
+	}
+
+	ctx := context.Background()
+
+	_, old_bucket, err := openaccess.OpenBucket(ctx, *old_bucket_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to open old bucket, %v", err)
+	}
+
+	defer old_bucket.Close()
+
+	_, new_bucket, err := openaccess.OpenBucket(ctx, *new_bucket_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to open new bucket, %v", err)
+	}
+
+	defer new_bucket.Close()
+
+	writers := make([]io.Writer, 0)
+
+	if *to_stdout {
+		writers = append(writers, os.Stdout)
+	}
+
+	if *to_devnull {
+		writers = append(writers, ioutil.Discard)
+	}
+
+	if len(writers) == 0 {
+		log.Fatal("Nothing to write to.")
+	}
+
+	wr := io.MultiWriter(writers...)
+
+	filter_func := func(ctx context.Context, uri string) bool {
+		// Skip things like index.txt' or errant 'fileblob*' records
+		return openaccess.IsMetaDataFile(uri)
+	}
+
+	uris := flag.Args()
+
+	if len(uris) == 0 {
+		uris = []string{openaccess.AWS_S3_METADATA}
+	}
+
+	changes := make([]*diff.Change, 0)
+
+	for _, uri := range uris {
+
+		walk_opts := &walk.WalkOptions{
+			URI:     uri,
+			Workers: *workers,
+			Filter:  filter_func,
+		}
+
+		if len(queries) > 0 {
+
+			qs := &query.QuerySet{
+				Queries: queries,
+				Mode:    *query_mode,
+			}
+
+			walk_opts.QuerySet = qs
+		}
+
+		opts := &diff.DiffOptions{
+			Old:    walk_opts,
+			New:    walk_opts,
+			Fields: *fields,
+		}
+
+		uri_changes, err := diff.Diff(ctx, opts, old_bucket, new_bucket)
+
+		if err != nil {
+			log.Fatalf("Failed to diff %s, %v", uri, err)
+		}
+
+		changes = append(changes, uri_changes...)
+	}
+
+	switch *format {
+	case "csv":
+		err = writeCSV(wr, changes, *csv_header)
+	default:
+		err = writeJSONL(wr, changes)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to write changes, %v", err)
+	}
+}
+
+func writeJSONL(wr io.Writer, changes []*diff.Change) error {
+
+	enc := json.NewEncoder(wr)
+
+	for _, c := range changes {
+
+		err := enc.Encode(c)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeCSV(wr io.Writer, changes []*diff.Change, header bool) error {
+
+	csv_wr := csv.NewWriter(wr)
+
+	if header {
+
+		header_row := []string{
+			"id",
+			"unit",
+			"change",
+			"old_path",
+			"old_line_number",
+			"new_path",
+			"new_line_number",
+			"fields",
+		}
+
+		err := csv_wr.Write(header_row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range changes {
+
+		row := []string{
+			c.Id,
+			c.Unit,
+			c.Type,
+			"",
+			"",
+			"",
+			"",
+			"",
+		}
+
+		if c.Old != nil {
+			row[3] = c.Old.Path
+			row[4] = strconv.Itoa(c.Old.LineNumber)
+		}
+
+		if c.New != nil {
+			row[5] = c.New.Path
+			row[6] = strconv.Itoa(c.New.LineNumber)
+		}
+
+		paths := make([]string, len(c.Fields))
+
+		for i, f := range c.Fields {
+			paths[i] = f.Path
+		}
+
+		row[7] = strings.Join(paths, ";")
+
+		err := csv_wr.Write(row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	csv_wr.Flush()
+	return csv_wr.Error()
+}
@@ -9,13 +9,22 @@ import (
 	"github.com/aaronland/go-json-query"
 	jw "github.com/aaronland/go-jsonl/walk"
 	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/changefeed"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
+	"github.com/aaronland/go-smithsonian-openaccess/diff"
+	"github.com/aaronland/go-smithsonian-openaccess/edan"
+	"github.com/aaronland/go-smithsonian-openaccess/incremental"
 	"github.com/aaronland/go-smithsonian-openaccess/oembed"
+	"github.com/aaronland/go-smithsonian-openaccess/progress"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
 	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/s3blob"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -39,8 +48,39 @@ func main() {
 
 	validate_edan := flag.Bool("validate-edan", false, "Ensure each record is a valid EDAN document.")
 
+	date_range := flag.String("date-range", "", "Only emit records whose content.freetext.date (or content.indexedStructured.date) values overlap this date range. Any value that can be parsed by edan.ParseDate is valid, for example: 1900/1920, 1840s, 19th century.")
+
+	since := flag.String("since", "", "Only emit records whose lastTimeUpdated property is equal to or after this time. Valid values are RFC3339 date-times, YYYY-MM-DD dates or Unix timestamps.")
+
+	state_uri := flag.String("incremental-state", "", "An optional URI used to record the MD5 hash, modification time and size of each metadata file that is processed. If the URI already exists only metadata files that have changed since it was last updated are processed. Valid URIs are a local path (or file:// URI) or a GoCloud bucket URI whose path is the key of the state file.")
+
+	changes_since := flag.String("changes-since", "", "A valid GoCloud bucket URI for an older snapshot of the OpenAccess dataset. If present then, rather than emitting records, emit upsert and delete events for the records that have been added, modified or removed since that snapshot. Records are compared using their id, hash and docSignature properties.")
+
 	stats := flag.Bool("stats", false, "Display timings and statistics.")
 
+	show_progress := flag.Bool("progress", false, "Periodically report the progress of the walk (files, bytes and records processed, errors, records per second and an estimate of the time remaining) to STDERR.")
+	progress_interval := flag.Duration("progress-interval", 10*time.Second, "How often to report progress when the -progress flag is true.")
+	metrics_address := flag.String("metrics-address", "", "If not empty, the address (for example localhost:9090) on which to expose the progress of the walk, as Prometheus text metrics, at the /metrics endpoint.")
+
+	checkpoint_uri := flag.String("checkpoint", "", "An optional checkpoint URI used to record which metadata files have been fully processed. Valid URIs are a local path (or file:// URI) or a GoCloud bucket URI whose path is the key of the checkpoint file.")
+	resume := flag.Bool("resume", false, "Skip metadata files that have already been recorded as fully processed in the -checkpoint URI. If false any existing checkpoint data is discarded.")
+
+	sample_size := flag.Int("sample", 0, "If greater than 0 only emit a uniform random sample of this many records, sorted by metadata file path and then line number. Sampling is applied after the -query flags but before any other filters.")
+	sample_per_unit := flag.Bool("sample-per-unit", false, "If true sample -sample records for each unit rather than for all the records combined.")
+	seed := flag.Int64("seed", 0, "The seed used to select sampled records. The same seed will always select the same records from the same data. If 0 a random seed is used.")
+
+	shard_index := flag.Int("shard", 0, "The (zero-based) index of the shard to process when the -shards flag is greater than 1.")
+	shard_count := flag.Int("shards", 1, "The total number of shards to partition metadata files in to. Each shard is assigned a deterministic subset of files, by unit and hash prefix, so that work can be distributed across multiple processes.")
+
+	valid_policies := strings.Join(walk.FailurePolicies(), ", ")
+	desc_policies := fmt.Sprintf("Specify what to do when a record can not be processed. Valid policies are: %s. In all cases the tool will exit with a non-zero status if any records failed.", valid_policies)
+
+	failure_policy := flag.String("failure-policy", walk.FAILURE_POLICY_SKIP, desc_policies)
+	max_errors := flag.Int("max-errors", 100, "The number of errors after which processing is stopped when the -failure-policy flag is \"max-errors\".")
+
+	ordered := flag.Bool("ordered", false, "Emit records in a deterministic order, sorted by metadata file path and then line number. Files are still read concurrently but output is buffered, per file, until all the preceding files have been emitted.")
+	ordered_buffer := flag.Int("ordered-buffer", walk.DEFAULT_ORDERED_BUFFER_SIZE, "The maximum number of records to buffer, per file, when the -ordered flag is true.")
+
 	var queries query.QueryFlags
 	flag.Var(&queries, "query", "One or more {PATH}={REGEXP} parameters for filtering records.")
 
@@ -58,6 +98,60 @@ func main() {
 
 	flag.Parse()
 
+	err := shard.Validate(*shard_index, *shard_count)
+
+	if err != nil {
+		log.Fatalf("Invalid -shard or -shards value, %v", err)
+	}
+
+	if *sample_size > 0 && *checkpoint_uri != "" {
+		log.Fatal("-sample can not be used with -checkpoint")
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	if *changes_since != "" {
+
+		// Every record in the new snapshot needs to be compared with the old snapshot, or it
+		// will be reported as deleted, so anything that skips records is not allowed
+
+		if *as_json || *as_oembed || *validate_edan || *date_range != "" || *since != "" || *sample_size > 0 || *state_uri != "" || *checkpoint_uri != "" {
+			log.Fatal("-changes-since can not be used with the -json, -oembed, -validate-edan, -date-range, -since, -sample, -incremental-state or -checkpoint flags")
+		}
+	}
+
+	if !walk.IsValidFailurePolicy(*failure_policy) {
+		log.Fatalf("Invalid -failure-policy value '%s'", *failure_policy)
+	}
+
+	var filter_since time.Time
+
+	if *since != "" {
+
+		t, err := openaccess.ParseTime(*since)
+
+		if err != nil {
+			log.Fatalf("Invalid -since value, %v", err)
+		}
+
+		filter_since = t
+	}
+
+	var filter_dates *edan.DateRange
+
+	if *date_range != "" {
+
+		r, err := edan.ParseDate(*date_range)
+
+		if err != nil {
+			log.Fatalf("Invalid -date-range value, %v", err)
+		}
+
+		filter_dates = r
+	}
+
 	ctx := context.Background()
 
 	ctx, bucket, err := openaccess.OpenBucket(ctx, *bucket_uri)
@@ -68,6 +162,63 @@ func main() {
 
 	defer bucket.Close()
 
+	var old_bucket *blob.Bucket
+
+	if *changes_since != "" {
+
+		_, b, err := openaccess.OpenBucket(ctx, *changes_since)
+
+		if err != nil {
+			log.Fatalf("Failed to open -changes-since bucket, %v", err)
+		}
+
+		defer b.Close()
+
+		old_bucket = b
+	}
+
+	var cp checkpoint.Checkpoint
+
+	if *checkpoint_uri != "" {
+
+		c, err := checkpoint.NewCheckpoint(ctx, *checkpoint_uri)
+
+		if err != nil {
+			log.Fatalf("Failed to create checkpoint, %v", err)
+		}
+
+		if !*resume {
+
+			err := c.Reset(ctx)
+
+			if err != nil {
+				log.Fatalf("Failed to reset checkpoint, %v", err)
+			}
+		}
+
+		cp = c
+
+	} else if *resume {
+		log.Fatal("-resume requires a -checkpoint URI")
+	}
+
+	var state *incremental.State
+
+	if *state_uri != "" {
+
+		if *sample_size > 0 {
+			log.Fatal("-sample can not be used with -incremental-state")
+		}
+
+		st, err := incremental.ReadState(ctx, *state_uri)
+
+		if err != nil {
+			log.Fatalf("Failed to read incremental state, %v", err)
+		}
+
+		state = st
+	}
+
 	writers := make([]io.Writer, 0)
 
 	if *to_stdout {
@@ -100,6 +251,31 @@ func main() {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	var monitor *progress.Monitor
+
+	if *show_progress || *metrics_address != "" {
+		monitor = progress.NewMonitor()
+	}
+
+	if *show_progress {
+		go progress.Report(ctx, monitor, os.Stderr, *progress_interval)
+	}
+
+	if *metrics_address != "" {
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", progress.Handler(monitor))
+
+		go func() {
+
+			err := http.ListenAndServe(*metrics_address, mux)
+
+			if err != nil {
+				log.Printf("Failed to serve metrics, %v", err)
+			}
+		}()
+	}
+
 	mu := new(sync.RWMutex)
 
 	write := func(ctx context.Context, records ...[]byte) error {
@@ -126,6 +302,8 @@ func main() {
 
 			wr.Write(body)
 			wr.Write([]byte("\n"))
+
+			monitor.RecordEmitted()
 		}
 
 		return nil
@@ -143,44 +321,74 @@ func main() {
 			return err
 		}
 
-		records := make([][]byte, 0)
-		var object *openaccess.OpenAccessRecord
+		return write(ctx, rec.Body)
+	}
+
+	// record_cb is used when records need to be inspected, in which case they are decoded by the walker
+
+	record_cb := func(ctx context.Context, rec *walk.Record, err error) error {
+
+		if err != nil {
+
+			if jw.IsEOFError(err) {
+				return nil
+			}
 
-		if *validate_edan || *as_oembed {
+			log.Println(err)
+			return err
+		}
+
+		object := rec.OpenAccessRecord
+
+		if *validate_edan {
 
-			err = json.Unmarshal(rec.Body, &object)
+			_, err = object.ContentRecord()
 
 			if err != nil {
-				log.Println(err)
+				log.Printf("Invalid EDAN content for record %s, %v\n", object.Id, err)
 				return err
 			}
+		}
+
+		if !filter_since.IsZero() && !object.UpdatedSince(filter_since) {
+			return nil
+		}
+
+		if filter_dates != nil {
+
+			r, err := object.DateRange()
+
+			if err != nil {
+				return nil
+			}
 
-			if *as_oembed {
+			if !r.Overlaps(filter_dates.Earliest, filter_dates.Latest) {
+				return nil
+			}
+		}
 
-				oembed_records, err := oembed.OEmbedRecordsFromOpenAccessRecord(object)
+		if !*as_oembed {
+			return write(ctx, rec.Body)
+		}
 
-				if err != nil {
-					// log.Printf("Unable to construct oembed records from object '%s': %v\n", object.Id, err)
-					return nil
-				}
+		oembed_records, err := oembed.OEmbedRecordsFromOpenAccessRecord(object)
 
-				for _, o_rec := range oembed_records {
+		if err != nil {
+			// log.Printf("Unable to construct oembed records from object '%s': %v\n", object.Id, err)
+			return nil
+		}
 
-					body, err := json.Marshal(o_rec)
+		records := make([][]byte, 0)
 
-					if err != nil {
-						return err
-					}
+		for _, o_rec := range oembed_records {
 
-					records = append(records, body)
-				}
+			body, err := json.Marshal(o_rec)
 
-			} else {
-				records = append(records, rec.Body)
+			if err != nil {
+				return err
 			}
 
-		} else {
-			records = append(records, rec.Body)
+			records = append(records, body)
 		}
 
 		return write(ctx, records...)
@@ -197,6 +405,8 @@ func main() {
 		return openaccess.IsMetaDataFile(uri)
 	}
 
+	var walk_err error
+
 	for _, uri := range uris {
 
 		opts := &walk.WalkOptions{
@@ -207,6 +417,27 @@ func main() {
 			Callback:     cb,
 			IsBzip:       false,
 			Filter:       filter_func,
+			Checkpoint:   cp,
+
+			Ordered:           *ordered,
+			OrderedBufferSize: *ordered_buffer,
+
+			ShardIndex: *shard_index,
+			ShardCount: *shard_count,
+
+			SampleSize:    *sample_size,
+			SamplePerUnit: *sample_per_unit,
+			SampleSeed:    *seed,
+
+			Progress: monitor,
+			State:    state,
+
+			FailurePolicy: *failure_policy,
+			MaxErrors:     *max_errors,
+		}
+
+		if *validate_edan || *as_oembed || filter_dates != nil || !filter_since.IsZero() {
+			opts.RecordCallback = record_cb
 		}
 
 		if len(queries) > 0 {
@@ -219,10 +450,37 @@ func main() {
 			opts.QuerySet = qs
 		}
 
+		if old_bucket != nil {
+
+			old_opts := &walk.WalkOptions{
+				URI:        uri,
+				Workers:    *workers,
+				Filter:     filter_func,
+				QuerySet:   opts.QuerySet,
+				ShardIndex: *shard_index,
+				ShardCount: *shard_count,
+			}
+
+			diff_opts := &diff.DiffOptions{
+				Old: old_opts,
+				New: opts,
+			}
+
+			err := changefeed.WriteChanges(ctx, wr, diff_opts, old_bucket, bucket)
+
+			if err != nil {
+				walk_err = fmt.Errorf("Failed to write changes for %s, %w", uri, err)
+				break
+			}
+
+			continue
+		}
+
 		err := walk.WalkBucket(ctx, opts, bucket)
 
 		if err != nil {
-			log.Fatalf("Failed to crawl %s, %v", uri, err)
+			walk_err = fmt.Errorf("Failed to crawl %s, %w", uri, err)
+			break
 		}
 	}
 
@@ -230,4 +488,36 @@ func main() {
 		wr.Write([]byte("]"))
 	}
 
+	if *show_progress {
+		fmt.Fprintln(os.Stderr, monitor.Snapshot().String())
+	}
+
+	// The state is saved even if the walk failed. Only the files that were processed without errors
+	// are recorded so any file that failed will be processed again the next time.
+
+	if state != nil {
+
+		err := state.Save(ctx, *state_uri)
+
+		if err != nil {
+			log.Fatalf("Failed to save incremental state, %v", err)
+		}
+	}
+
+	// Checkpoints may buffer the keys that have been marked as complete so they are closed explicitly, rather
+	// than deferred, because deferred functions aren't run when exiting with log.Fatal or os.Exit
+
+	if cp != nil {
+
+		err := cp.Close(ctx)
+
+		if err != nil {
+			log.Fatalf("Failed to close checkpoint, %v", err)
+		}
+	}
+
+	if walk_err != nil {
+		log.Fatal(walk_err)
+	}
+
 }
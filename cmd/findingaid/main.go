@@ -3,17 +3,21 @@ package main
 import (
 	"context"
 	"encoding/csv"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/aaronland/go-json-query"
 	jw "github.com/aaronland/go-jsonl/walk"
 	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
+	"github.com/aaronland/go-smithsonian-openaccess/findingaid"
+	"github.com/aaronland/go-smithsonian-openaccess/progress"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
 	"github.com/aaronland/go-smithsonian-openaccess/walk"
 	_ "gocloud.dev/blob/fileblob"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -32,6 +36,29 @@ func main() {
 
 	stats := flag.Bool("stats", false, "Display timings and statistics.")
 
+	show_progress := flag.Bool("progress", false, "Periodically report the progress of the walk (files, bytes and records processed, errors, records per second and an estimate of the time remaining) to STDERR.")
+	progress_interval := flag.Duration("progress-interval", 10*time.Second, "How often to report progress when the -progress flag is true.")
+	metrics_address := flag.String("metrics-address", "", "If not empty, the address (for example localhost:9090) on which to expose the progress of the walk, as Prometheus text metrics, at the /metrics endpoint.")
+
+	checkpoint_uri := flag.String("checkpoint", "", "An optional checkpoint URI used to record which metadata files have been fully processed. Valid URIs are a local path (or file:// URI) or a GoCloud bucket URI whose path is the key of the checkpoint file.")
+	resume := flag.Bool("resume", false, "Skip metadata files that have already been recorded as fully processed in the -checkpoint URI. If false any existing checkpoint data is discarded.")
+
+	sample_size := flag.Int("sample", 0, "If greater than 0 only emit a uniform random sample of this many records, sorted by metadata file path and then line number. Sampling is applied after the -query flags but before any other filters.")
+	sample_per_unit := flag.Bool("sample-per-unit", false, "If true sample -sample records for each unit rather than for all the records combined.")
+	seed := flag.Int64("seed", 0, "The seed used to select sampled records. The same seed will always select the same records from the same data. If 0 a random seed is used.")
+
+	shard_index := flag.Int("shard", 0, "The (zero-based) index of the shard to process when the -shards flag is greater than 1.")
+	shard_count := flag.Int("shards", 1, "The total number of shards to partition metadata files in to. Each shard is assigned a deterministic subset of files, by unit and hash prefix, so that work can be distributed across multiple processes.")
+
+	valid_policies := strings.Join(walk.FailurePolicies(), ", ")
+	desc_policies := fmt.Sprintf("Specify what to do when a record can not be processed. Valid policies are: %s. In all cases the tool will exit with a non-zero status if any records failed.", valid_policies)
+
+	failure_policy := flag.String("failure-policy", walk.FAILURE_POLICY_SKIP, desc_policies)
+	max_errors := flag.Int("max-errors", 100, "The number of errors after which processing is stopped when the -failure-policy flag is \"max-errors\".")
+
+	ordered := flag.Bool("ordered", false, "Emit records in a deterministic order, sorted by metadata file path and then line number. Files are still read concurrently but output is buffered, per file, until all the preceding files have been emitted.")
+	ordered_buffer := flag.Int("ordered-buffer", walk.DEFAULT_ORDERED_BUFFER_SIZE, "The maximum number of records to buffer, per file, when the -ordered flag is true.")
+
 	var queries query.QueryFlags
 	flag.Var(&queries, "query", "One or more {PATH}={REGEXP} parameters for filtering records.")
 
@@ -46,7 +73,11 @@ func main() {
 	include_openaccess_id := flag.Bool("include-openaccess-id", false, "Include the OpenAccess `id` identifier")
 	include_all := flag.Bool("include-all", false, "Include all OpenAccess identifiers")
 
-	csv_header := flag.Bool("csv-header", true, "Include a CSV header row in the output")
+	valid_formats := strings.Join([]string{"csv", "index"}, ", ")
+	desc_formats := fmt.Sprintf("The format to emit the finding aid in. \"index\" is a compact finding aid index, which lists each metadata file only once and is written after all the records have been processed. Valid formats are: %s", valid_formats)
+
+	format := flag.String("format", "csv", desc_formats)
+	csv_header := flag.Bool("csv-header", true, "Include a CSV header row in the output. This flag is ignored if -format is \"index\".")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage:\n")
@@ -57,6 +88,38 @@ func main() {
 
 	flag.Parse()
 
+	switch *format {
+	case "csv", "index":
+		// pass
+	default:
+		log.Fatalf("Invalid -format value '%s'", *format)
+	}
+
+	// Indexes are only written once all the records have been processed so a resumed walk would produce
+	// an index that is missing all the files that were completed by an earlier walk
+
+	if *format == "index" && (*checkpoint_uri != "" || *resume) {
+		log.Fatal("-format index can not be used with -checkpoint or -resume")
+	}
+
+	err := shard.Validate(*shard_index, *shard_count)
+
+	if err != nil {
+		log.Fatalf("Invalid -shard or -shards value, %v", err)
+	}
+
+	if *sample_size > 0 && *checkpoint_uri != "" {
+		log.Fatal("-sample can not be used with -checkpoint")
+	}
+
+	if !walk.IsValidFailurePolicy(*failure_policy) {
+		log.Fatalf("Invalid -failure-policy value '%s'", *failure_policy)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
 	if *include_all {
 		*include_guid = true
 		*include_record_id = true
@@ -74,6 +137,31 @@ func main() {
 
 	defer bucket.Close()
 
+	var cp checkpoint.Checkpoint
+
+	if *checkpoint_uri != "" {
+
+		c, err := checkpoint.NewCheckpoint(ctx, *checkpoint_uri)
+
+		if err != nil {
+			log.Fatalf("Failed to create checkpoint, %v", err)
+		}
+
+		if !*resume {
+
+			err := c.Reset(ctx)
+
+			if err != nil {
+				log.Fatalf("Failed to reset checkpoint, %v", err)
+			}
+		}
+
+		cp = c
+
+	} else if *resume {
+		log.Fatal("-resume requires a -checkpoint URI")
+	}
+
 	writers := make([]io.Writer, 0)
 
 	if *to_stdout {
@@ -107,9 +195,42 @@ func main() {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	var monitor *progress.Monitor
+
+	if *show_progress || *metrics_address != "" {
+		monitor = progress.NewMonitor()
+	}
+
+	if *show_progress {
+		go progress.Report(ctx, monitor, os.Stderr, *progress_interval)
+	}
+
+	if *metrics_address != "" {
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", progress.Handler(monitor))
+
+		go func() {
+
+			err := http.ListenAndServe(*metrics_address, mux)
+
+			if err != nil {
+				log.Printf("Failed to serve metrics, %v", err)
+			}
+		}()
+	}
+
 	mu := new(sync.RWMutex)
 
-	write := func(ctx context.Context, rec *jw.WalkRecord, ids ...string) error {
+	// Finding aid indexes are sorted by path and line number so they are assembled in memory and written at the end
+
+	var index *findingaid.FindingAid
+
+	if *format == "index" {
+		index = findingaid.NewFindingAid()
+	}
+
+	write := func(ctx context.Context, rec *walk.Record, ids ...string) error {
 
 		mu.Lock()
 		defer mu.Unlock()
@@ -123,14 +244,26 @@ func main() {
 				// pass
 			}
 
+			new_count := atomic.AddUint32(&count, 1)
+
+			if index != nil {
+
+				e := &findingaid.Entry{
+					Id:         id,
+					Path:       rec.Path,
+					LineNumber: rec.LineNumber,
+				}
+
+				index.Add(e)
+				continue
+			}
+
 			row := []string{
 				id,
 				rec.Path,
 				strconv.Itoa(rec.LineNumber),
 			}
 
-			new_count := atomic.AddUint32(&count, 1)
-
 			if new_count == 1 && *csv_header {
 
 				header_row := []string{
@@ -139,7 +272,7 @@ func main() {
 					"line_number",
 				}
 
-				err = csv_wr.Write(header_row)
+				err := csv_wr.Write(header_row)
 
 				if err != nil {
 					return err
@@ -147,32 +280,31 @@ func main() {
 
 			}
 
-			err = csv_wr.Write(row)
+			err := csv_wr.Write(row)
 
 			if err != nil {
 				return err
 			}
 		}
 
+		monitor.RecordEmitted()
 		return nil
 	}
 
-	cb := func(ctx context.Context, rec *jw.WalkRecord, err error) error {
+	cb := func(ctx context.Context, rec *walk.Record, err error) error {
 
 		if err != nil {
-			log.Println(err)
-			return err
-		}
 
-		var object *openaccess.OpenAccessRecord
-
-		err = json.Unmarshal(rec.Body, &object)
+			if jw.IsEOFError(err) {
+				return nil
+			}
 
-		if err != nil {
 			log.Println(err)
 			return err
 		}
 
+		object := rec.OpenAccessRecord
+
 		ids := make([]string, 0)
 
 		if *include_guid {
@@ -225,8 +357,21 @@ func main() {
 		return write(ctx, rec, ids...)
 	}
 
+	// Flush buffered rows before a file is marked as complete so that they aren't lost if the process is interrupted
+
+	complete_cb := func(ctx context.Context, path string) error {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		csv_wr.Flush()
+		return csv_wr.Error()
+	}
+
 	uris := flag.Args()
 
+	var walk_err error
+
 	filter_func := func(ctx context.Context, uri string) bool {
 		// Skip things like index.txt' or errant 'fileblob*' records
 		return openaccess.IsMetaDataFile(uri)
@@ -235,12 +380,30 @@ func main() {
 	for _, uri := range uris {
 
 		opts := &walk.WalkOptions{
-			URI:          uri,
-			Workers:      *workers,
-			FormatJSON:   false,
-			ValidateJSON: false,
-			Callback:     cb,
-			Filter:       filter_func,
+			URI:            uri,
+			Workers:        *workers,
+			FormatJSON:     false,
+			ValidateJSON:   false,
+			RecordCallback: cb,
+			Filter:         filter_func,
+			Checkpoint:     cp,
+
+			CompleteCallback: complete_cb,
+
+			Ordered:           *ordered,
+			OrderedBufferSize: *ordered_buffer,
+
+			ShardIndex: *shard_index,
+			ShardCount: *shard_count,
+
+			SampleSize:    *sample_size,
+			SamplePerUnit: *sample_per_unit,
+			SampleSeed:    *seed,
+
+			Progress: monitor,
+
+			FailurePolicy: *failure_policy,
+			MaxErrors:     *max_errors,
 		}
 
 		if len(queries) > 0 {
@@ -256,7 +419,23 @@ func main() {
 		err := walk.WalkBucket(ctx, opts, bucket)
 
 		if err != nil {
-			log.Fatalf("Failed to crawl %s, %v", uri, err)
+			walk_err = fmt.Errorf("Failed to crawl %s, %w", uri, err)
+			break
+		}
+	}
+
+	if *show_progress {
+		fmt.Fprintln(os.Stderr, monitor.Snapshot().String())
+	}
+
+	// The records that were processed are written (or flushed) even if the walk failed
+
+	if index != nil {
+
+		err = index.WriteIndex(wr)
+
+		if err != nil {
+			log.Fatalf("Failed to write finding aid index, %v", err)
 		}
 	}
 
@@ -267,4 +446,20 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// Checkpoints may buffer the keys that have been marked as complete so they are closed explicitly, rather
+	// than deferred, because deferred functions aren't run when exiting with log.Fatal or os.Exit
+
+	if cp != nil {
+
+		err := cp.Close(ctx)
+
+		if err != nil {
+			log.Fatalf("Failed to close checkpoint, %v", err)
+		}
+	}
+
+	if walk_err != nil {
+		log.Fatal(walk_err)
+	}
 }
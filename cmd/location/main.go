@@ -92,8 +92,8 @@ func main() {
 				return
 			}
 
-			// "content.indexStructured.geoLocation",
-			// "content.indexStructured.place",
+			// "content.indexedStructured.geoLocation",
+			// "content.indexedStructured.place",
 			// "content.freetext.place",
 
 			iim_record := openaccess_record.Content
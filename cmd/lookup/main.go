@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/findingaid"
+	"github.com/aaronland/go-smithsonian-openaccess/lookup"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/s3blob"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	bucket_uri := flag.String("bucket-uri", "", "A valid GoCloud bucket URI. Valid schemes are: file://, s3:// and si:// which is signals that data should be retrieved from the Smithsonian's 'smithsonian-open-access' S3 bucket.")
+	finding_aid_path := flag.String("finding-aid", "", "The path to a finding aid produced by the findingaid tool. This may be either a CSV file or a compact finding aid index.")
+	workers := flag.Int("workers", lookup.DEFAULT_WORKERS, "The maximum number of metadata files to read concurrently.")
+	batch_size := flag.Int("batch-size", 1000, "The number of identifiers to look up at once. Identifiers in the same batch that reference the same metadata file are fetched by reading that file once.")
+
+	as_json := flag.Bool("json", false, "Emit a JSON list.")
+	format_json := flag.Bool("format-json", false, "Format JSON output for each record.")
+
+	to_stdout := flag.Bool("stdout", true, "Emit to STDOUT")
+	to_devnull := flag.Bool("null", false, "Emit to /dev/null")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [options] [id1 id2 ... idN]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "If no identifiers are passed, or the only identifier is \"-\", then identifiers are read from STDIN, one per line.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *finding_aid_path == "" {
+		log.Fatal("Missing -finding-aid")
+	}
+
+	if *batch_size < 1 {
+		log.Fatal("-batch-size must be greater than 0")
+	}
+
+	ctx := context.Background()
+
+	fa_fh, err := os.Open(*finding_aid_path)
+
+	if err != nil {
+		log.Fatalf("Failed to open finding aid, %v", err)
+	}
+
+	fa, err := findingaid.ReadFindingAid(fa_fh)
+
+	fa_fh.Close()
+
+	if err != nil {
+		log.Fatalf("Failed to read finding aid, %v", err)
+	}
+
+	ctx, bucket, err := openaccess.OpenBucket(ctx, *bucket_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	defer bucket.Close()
+
+	writers := make([]io.Writer, 0)
+
+	if *to_stdout {
+		writers = append(writers, os.Stdout)
+	}
+
+	if *to_devnull {
+		writers = append(writers, ioutil.Discard)
+	}
+
+	if len(writers) == 0 {
+		log.Fatal("Nothing to write to.")
+	}
+
+	wr := io.MultiWriter(writers...)
+
+	l := lookup.NewLookup(bucket, fa)
+	l.Workers = *workers
+
+	count := 0
+	failed := 0
+
+	process := func(ids []string) error {
+
+		results, err := l.GetMany(ctx, ids)
+
+		if err != nil {
+			return err
+		}
+
+		for _, r := range results {
+
+			if r.Err != nil {
+				log.Printf("Failed to look up '%s', %v", r.Id, r.Err)
+				failed += 1
+				continue
+			}
+
+			body := r.Record.Body
+
+			if *format_json {
+
+				var buf bytes.Buffer
+
+				err := json.Indent(&buf, body, "", "  ")
+
+				if err != nil {
+					return fmt.Errorf("Failed to format '%s', %w", r.Id, err)
+				}
+
+				body = buf.Bytes()
+			}
+
+			count += 1
+
+			if *as_json && count > 1 {
+				wr.Write([]byte(","))
+			}
+
+			wr.Write(body)
+			wr.Write([]byte("\n"))
+		}
+
+		return nil
+	}
+
+	if *as_json {
+		wr.Write([]byte("["))
+	}
+
+	ids := flag.Args()
+
+	if len(ids) == 0 || (len(ids) == 1 && ids[0] == "-") {
+
+		scanner := bufio.NewScanner(os.Stdin)
+		batch := make([]string, 0)
+
+		for scanner.Scan() {
+
+			id := strings.TrimSpace(scanner.Text())
+
+			if id == "" {
+				continue
+			}
+
+			batch = append(batch, id)
+
+			if len(batch) < *batch_size {
+				continue
+			}
+
+			err := process(batch)
+
+			if err != nil {
+				log.Fatalf("Failed to look up identifiers, %v", err)
+			}
+
+			batch = make([]string, 0)
+		}
+
+		err := scanner.Err()
+
+		if err != nil {
+			log.Fatalf("Failed to read identifiers, %v", err)
+		}
+
+		ids = batch
+	}
+
+	for len(ids) > 0 {
+
+		size := *batch_size
+
+		if size > len(ids) {
+			size = len(ids)
+		}
+
+		err := process(ids[0:size])
+
+		if err != nil {
+			log.Fatalf("Failed to look up identifiers, %v", err)
+		}
+
+		ids = ids[size:]
+	}
+
+	if *as_json {
+		wr.Write([]byte("]"))
+	}
+
+	if failed > 0 {
+		log.Fatalf("Failed to look up %d identifier(s)", failed)
+	}
+}
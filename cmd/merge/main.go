@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	valid_formats := strings.Join([]string{"csv", "jsonl"}, ", ")
+	desc_formats := fmt.Sprintf("The format of the files being merged. Valid formats are: %s", valid_formats)
+
+	format := flag.String("format", "jsonl", desc_formats)
+	csv_header := flag.Bool("csv-header", true, "Each CSV file starts with a header row. If true the header is only written once and rows from findingaid output are merged in path and line number order.")
+
+	to_stdout := flag.Bool("stdout", true, "Emit to STDOUT")
+	to_devnull := flag.Bool("null", false, "Emit to /dev/null")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [options] path1 path2 ... pathN\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	ctx := context.Background()
+
+	writers := make([]io.Writer, 0)
+
+	if *to_stdout {
+		writers = append(writers, os.Stdout)
+	}
+
+	if *to_devnull {
+		writers = append(writers, ioutil.Discard)
+	}
+
+	if len(writers) == 0 {
+		log.Fatal("Nothing to write to.")
+	}
+
+	wr := io.MultiWriter(writers...)
+
+	paths := flag.Args()
+	readers := make([]io.Reader, len(paths))
+
+	for i, path := range paths {
+
+		fh, err := os.Open(path)
+
+		if err != nil {
+			log.Fatalf("Failed to open %s, %v", path, err)
+		}
+
+		defer fh.Close()
+
+		readers[i] = fh
+	}
+
+	var err error
+
+	switch *format {
+	case "csv":
+		err = shard.MergeCSV(ctx, wr, *csv_header, readers...)
+	case "jsonl":
+		err = shard.MergeJSONL(ctx, wr, readers...)
+	default:
+		log.Fatalf("Invalid -format value '%s'", *format)
+	}
+
+	if err != nil {
+		log.Fatalf("Failed to merge files, %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/edan"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func main() {
+
+	to_stdout := flag.Bool("stdout", true, "Emit to STDOUT")
+	to_devnull := flag.Bool("null", false, "Emit to /dev/null")
+
+	uniq := flag.Bool("unique", true, "Only emit each unique agent (name, role, nationality, birth and death year) once.")
+	csv_header := flag.Bool("csv-header", true, "Include a CSV header row in the output")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	ctx := context.Background()
+
+	writers := make([]io.Writer, 0)
+
+	if *to_stdout {
+		writers = append(writers, os.Stdout)
+	}
+
+	if *to_devnull {
+		writers = append(writers, ioutil.Discard)
+	}
+
+	if len(writers) == 0 {
+		log.Fatal("Nothing to write to.")
+	}
+
+	wr := io.MultiWriter(writers...)
+	csv_wr := csv.NewWriter(wr)
+
+	if *csv_header {
+
+		header_row := []string{
+			"id",
+			"name",
+			"role",
+			"nationality",
+			"birth_year",
+			"death_year",
+		}
+
+		err := csv_wr.Write(header_row)
+
+		if err != nil {
+			log.Fatalf("Failed to write header, %v", err)
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	wg := new(sync.WaitGroup)
+	mu := new(sync.RWMutex)
+
+	seen := new(sync.Map)
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			break
+		default:
+			// pass
+		}
+
+		body, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			log.Fatalf("Failed to read bytes, %v", err)
+		}
+
+		body = bytes.TrimSpace(body)
+
+		wg.Add(1)
+
+		go func(body []byte) {
+
+			defer func() {
+				wg.Done()
+			}()
+
+			var openaccess_record *openaccess.OpenAccessRecord
+
+			err := json.Unmarshal(body, &openaccess_record)
+
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			for _, a := range openaccess_record.Agents() {
+
+				if *uniq {
+
+					key := agentKey(a)
+					_, loaded := seen.LoadOrStore(key, true)
+
+					if loaded {
+						continue
+					}
+				}
+
+				row := []string{
+					openaccess_record.Id,
+					a.Name,
+					a.Role,
+					a.Nationality,
+					formatYear(a.BirthYear),
+					formatYear(a.DeathYear),
+				}
+
+				mu.Lock()
+				err := csv_wr.Write(row)
+				mu.Unlock()
+
+				if err != nil {
+					log.Println(err)
+				}
+			}
+
+		}(body)
+	}
+
+	wg.Wait()
+
+	csv_wr.Flush()
+
+	err := csv_wr.Error()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func agentKey(a *edan.Agent) string {
+
+	parts := []string{
+		strings.ToLower(a.Name),
+		strings.ToLower(a.Role),
+		strings.ToLower(a.Nationality),
+		strconv.Itoa(a.BirthYear),
+		strconv.Itoa(a.DeathYear),
+	}
+
+	return strings.Join(parts, "#")
+}
+
+func formatYear(y int) string {
+
+	if y == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(y)
+}
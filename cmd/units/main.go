@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/units"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/s3blob"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+func main() {
+
+	bucket_uri := flag.String("bucket-uri", "", "A valid GoCloud bucket URI. Valid schemes are: file://, s3:// and si:// which is signals that data should be retrieved from the Smithsonian's 'smithsonian-open-access' S3 bucket. If empty then only the list of known units will be emitted.")
+	workers := flag.Int("workers", 10, "The maximum number of concurrent workers. This is used to prevent filehandle exhaustion.")
+
+	csv_header := flag.Bool("csv-header", true, "Include a CSV header row in the output")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [options] [path1 path2 ... pathN]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	ctx := context.Background()
+
+	csv_wr := csv.NewWriter(os.Stdout)
+
+	if *csv_header {
+
+		header_row := []string{
+			"code",
+			"name",
+			"url",
+			"metadata_prefix",
+		}
+
+		if *bucket_uri != "" {
+			header_row = append(header_row, "count")
+		}
+
+		err := csv_wr.Write(header_row)
+
+		if err != nil {
+			log.Fatalf("Failed to write header, %v", err)
+		}
+	}
+
+	if *bucket_uri == "" {
+
+		for _, u := range units.Units() {
+
+			row := []string{
+				u.Code,
+				u.Name,
+				u.URL,
+				u.MetadataPrefix,
+			}
+
+			err := csv_wr.Write(row)
+
+			if err != nil {
+				log.Fatalf("Failed to write row, %v", err)
+			}
+		}
+
+		csv_wr.Flush()
+
+		err := csv_wr.Error()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	ctx, bucket, err := openaccess.OpenBucket(ctx, *bucket_uri)
+
+	if err != nil {
+		log.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	defer bucket.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	counts := make(map[string]int64)
+
+	filter_func := func(ctx context.Context, uri string) bool {
+		return openaccess.IsMetaDataFile(uri)
+	}
+
+	uris := flag.Args()
+
+	if len(uris) == 0 {
+		uris = []string{openaccess.AWS_S3_METADATA}
+	}
+
+	for _, uri := range uris {
+
+		opts := &walk.WalkOptions{
+			URI:     uri,
+			Workers: *workers,
+			Filter:  filter_func,
+		}
+
+		err := countRecords(ctx, opts, bucket, counts)
+
+		if err != nil {
+			log.Fatalf("Failed to crawl %s, %v", uri, err)
+		}
+	}
+
+	codes := make([]string, 0)
+
+	for code := range counts {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	for _, code := range codes {
+
+		u, err := units.GetUnit(code)
+
+		if err != nil {
+			u = units.DefaultUnit(code)
+		}
+
+		row := []string{
+			u.Code,
+			u.Name,
+			u.URL,
+			u.MetadataPrefix,
+			strconv.FormatInt(counts[code], 10),
+		}
+
+		err = csv_wr.Write(row)
+
+		if err != nil {
+			log.Fatalf("Failed to write row, %v", err)
+		}
+	}
+
+	csv_wr.Flush()
+
+	err = csv_wr.Error()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func countRecords(ctx context.Context, opts *walk.WalkOptions, bucket *blob.Bucket, counts map[string]int64) error {
+
+	iter, err := walk.NewIterator(ctx, opts, bucket)
+
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	for {
+
+		rec, err := iter.Next(ctx)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// Metadata files are stored as metadata/edan/{UNIT}/{HASH}.txt
+
+		code := filepath.Base(filepath.Dir(rec.Path))
+		counts[code] += 1
+	}
+
+	return nil
+}
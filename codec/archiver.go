@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"github.com/mholt/archiver/v3"
+	"io"
+)
+
+// ArchiverCompressor is the subset of the mholt/archiver methods used by ArchiverCodec.
+
+type ArchiverCompressor interface {
+	archiver.Compressor
+	archiver.Decompressor
+}
+
+// ArchiverCodec implements the Codec interface using the (single file) compressors in the mholt/archiver package.
+
+type ArchiverCodec struct {
+	name       string
+	extension  string
+	compressor ArchiverCompressor
+}
+
+// NewArchiverCodec returns a new ArchiverCodec instance named name, for files ending in extension, using compressor.
+
+func NewArchiverCodec(name string, extension string, compressor ArchiverCompressor) *ArchiverCodec {
+
+	c := &ArchiverCodec{
+		name:       name,
+		extension:  extension,
+		compressor: compressor,
+	}
+
+	return c
+}
+
+func (c *ArchiverCodec) Name() string {
+	return c.name
+}
+
+func (c *ArchiverCodec) Extension() string {
+	return c.extension
+}
+
+func (c *ArchiverCodec) Compress(in io.Reader, out io.Writer) error {
+	return c.compressor.Compress(in, out)
+}
+
+// NewReader returns an io.ReadCloser that decompresses in, in a separate goroutine, as it is read. Decompression errors
+// are returned by Read. Closing the reader before it has been read to the end stops decompression.
+
+func (c *ArchiverCodec) NewReader(in io.Reader) (io.ReadCloser, error) {
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		err := c.compressor.Decompress(in, pw)
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
@@ -0,0 +1,153 @@
+// package codec provides a registry of compression codecs, identified by name and file extension, used to
+// compress cloned OpenAccess files and to decompress them when they are walked.
+package codec
+
+import (
+	"fmt"
+	"github.com/mholt/archiver/v3"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const BZIP2 string = "bzip2"
+
+const GZIP string = "gzip"
+
+const XZ string = "xz"
+
+const ZSTD string = "zstd"
+
+// Codec compresses and decompresses data for a single compression format.
+
+type Codec interface {
+	// The unique name of the codec, for example "zstd".
+	Name() string
+	// The file extension, including the leading ".", for files compressed with the codec. For example ".zst".
+	Extension() string
+	// Compress reads in, compresses it, and writes it to out.
+	Compress(in io.Reader, out io.Writer) error
+	// NewReader returns an io.ReadCloser that decompresses the data read from in. Closing it does not close in.
+	NewReader(in io.Reader) (io.ReadCloser, error)
+}
+
+var codecs map[string]Codec
+
+var codecs_mu *sync.RWMutex
+
+func init() {
+
+	codecs = make(map[string]Codec)
+	codecs_mu = new(sync.RWMutex)
+
+	RegisterCodec(NewArchiverCodec(BZIP2, ".bz2", archiver.NewBz2()))
+	RegisterCodec(NewArchiverCodec(GZIP, ".gz", archiver.NewGz()))
+	RegisterCodec(NewArchiverCodec(XZ, ".xz", archiver.NewXz()))
+	RegisterCodec(NewArchiverCodec(ZSTD, ".zst", archiver.NewZstd()))
+}
+
+// RegisterCodec makes c available by name and by extension. It is an error to register two codecs with the same
+// name or extension.
+
+func RegisterCodec(c Codec) error {
+
+	codecs_mu.Lock()
+	defer codecs_mu.Unlock()
+
+	_, exists := codecs[c.Name()]
+
+	if exists {
+		return fmt.Errorf("Codec '%s' is already registered", c.Name())
+	}
+
+	for _, other := range codecs {
+
+		if other.Extension() == c.Extension() {
+			return fmt.Errorf("Extension '%s' is already registered by codec '%s'", c.Extension(), other.Name())
+		}
+	}
+
+	codecs[c.Name()] = c
+	return nil
+}
+
+// NewCodec returns the codec registered as name.
+
+func NewCodec(name string) (Codec, error) {
+
+	codecs_mu.RLock()
+	defer codecs_mu.RUnlock()
+
+	c, ok := codecs[name]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown codec '%s'", name)
+	}
+
+	return c, nil
+}
+
+// CodecForPath returns the codec whose extension path ends with, if any.
+
+func CodecForPath(path string) (Codec, bool) {
+
+	codecs_mu.RLock()
+	defer codecs_mu.RUnlock()
+
+	for _, c := range codecs {
+
+		if strings.HasSuffix(path, c.Extension()) {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// TrimExtension returns path without the extension of the codec it is compressed with, if any.
+
+func TrimExtension(path string) string {
+
+	c, ok := CodecForPath(path)
+
+	if !ok {
+		return path
+	}
+
+	return strings.TrimSuffix(path, c.Extension())
+}
+
+// Codecs returns the names of all the registered codecs, sorted alphabetically.
+
+func Codecs() []string {
+
+	codecs_mu.RLock()
+	defer codecs_mu.RUnlock()
+
+	names := make([]string, 0)
+
+	for name := range codecs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Extensions returns the extensions of all the registered codecs, sorted alphabetically.
+
+func Extensions() []string {
+
+	codecs_mu.RLock()
+	defer codecs_mu.RUnlock()
+
+	extensions := make([]string, 0)
+
+	for _, c := range codecs {
+		extensions = append(extensions, c.Extension())
+	}
+
+	sort.Strings(extensions)
+	return extensions
+}
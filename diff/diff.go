@@ -0,0 +1,299 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// FieldChange is a single difference between the properties of two versions of a record.
+
+type FieldChange struct {
+	// The path of the property, for example "content.freetext.date[0].content".
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Change is a record that has been added, removed or modified between two snapshots.
+
+type Change struct {
+	Id   string `json:"id"`
+	Unit string `json:"unit"`
+	// One of the CHANGE_ADDED, CHANGE_REMOVED or CHANGE_MODIFIED constants.
+	Type string `json:"change"`
+	// The record's entry in the old snapshot, if present.
+	Old *Entry `json:"old,omitempty"`
+	// The record's entry in the new snapshot, if present.
+	New *Entry `json:"new,omitempty"`
+	// The differences between the old and new versions of a modified record, if requested.
+	Fields []*FieldChange `json:"fields,omitempty"`
+}
+
+// DiffOptions defines how two snapshots are compared.
+
+type DiffOptions struct {
+	// The options used to walk the old snapshot. The Callback and RecordCallback properties are ignored.
+	Old *walk.WalkOptions
+	// The options used to walk the new snapshot. The Callback and RecordCallback properties are ignored.
+	New *walk.WalkOptions
+	// If true then field-level differences are derived for modified records. This requires the old snapshot to
+	// be walked a second time and the new version of every modified record to be held in memory.
+	Fields bool
+}
+
+// Diff compares the records in old_bucket with the records in new_bucket, keyed by their `id` property, and returns
+// the records that have been added, removed or modified sorted by `id`. Records are considered to be modified if their
+// `hash` or `docSignature` properties differ (or, if they have neither, the MD5 hash of their bodies).
+
+func Diff(ctx context.Context, opts *DiffOptions, old_bucket *blob.Bucket, new_bucket *blob.Bucket) ([]*Change, error) {
+
+	old_snapshot, err := IndexSnapshot(ctx, opts.Old, old_bucket)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to index old snapshot, %w", err)
+	}
+
+	changes := make([]*Change, 0)
+	modified := make(map[string]*Change)
+	new_bodies := make(map[string][]byte)
+
+	mu := new(sync.Mutex)
+
+	new_cb := func(ctx context.Context, rec *walk.Record, err error) error {
+
+		if err != nil {
+
+			if jw.IsEOFError(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		new_entry := NewEntry(rec)
+		change_type, old_entry := old_snapshot.Compare(new_entry)
+
+		if change_type == CHANGE_UNCHANGED {
+			return nil
+		}
+
+		c := &Change{
+			Id:   new_entry.Id,
+			Unit: new_entry.Unit,
+			Type: change_type,
+			Old:  old_entry,
+			New:  new_entry,
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		changes = append(changes, c)
+
+		if change_type == CHANGE_MODIFIED && opts.Fields {
+			modified[c.Id] = c
+			new_bodies[c.Id] = rec.Body
+		}
+
+		return nil
+	}
+
+	new_opts := *opts.New
+	new_opts.RecordCallback = new_cb
+
+	err = walk.WalkBucket(ctx, &new_opts, new_bucket)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to walk new snapshot, %w", err)
+	}
+
+	for _, e := range old_snapshot.Unseen() {
+
+		c := &Change{
+			Id:   e.Id,
+			Unit: e.Unit,
+			Type: CHANGE_REMOVED,
+			Old:  e,
+		}
+
+		changes = append(changes, c)
+	}
+
+	if len(modified) > 0 {
+
+		old_cb := func(ctx context.Context, rec *walk.Record, err error) error {
+
+			if err != nil {
+
+				if jw.IsEOFError(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			id := rec.OpenAccessRecord.Id
+
+			mu.Lock()
+			c, ok := modified[id]
+			new_body := new_bodies[id]
+			mu.Unlock()
+
+			if !ok {
+				return nil
+			}
+
+			fields, err := FieldChanges(rec.Body, new_body)
+
+			if err != nil {
+				return fmt.Errorf("Failed to derive field changes for %s, %w", id, err)
+			}
+
+			mu.Lock()
+			c.Fields = fields
+			mu.Unlock()
+
+			return nil
+		}
+
+		old_opts := *opts.Old
+		old_opts.RecordCallback = old_cb
+
+		err = walk.WalkBucket(ctx, &old_opts, old_bucket)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to walk old snapshot, %w", err)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Id < changes[j].Id
+	})
+
+	return changes, nil
+}
+
+// FieldChanges returns the differences between the JSON-encoded records old_body and new_body, sorted by path.
+
+func FieldChanges(old_body []byte, new_body []byte) ([]*FieldChange, error) {
+
+	old_value, err := decodeValue(old_body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	new_value, err := decodeValue(new_body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*FieldChange, 0)
+	compareValues("", old_value, new_value, &changes)
+
+	return changes, nil
+}
+
+func decodeValue(body []byte) (interface{}, error) {
+
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	err := dec.Decode(&v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func compareValues(path string, old_value interface{}, new_value interface{}, changes *[]*FieldChange) {
+
+	old_map, old_ok := old_value.(map[string]interface{})
+	new_map, new_ok := new_value.(map[string]interface{})
+
+	if old_ok && new_ok {
+
+		keys := make([]string, 0)
+
+		for k := range old_map {
+			keys = append(keys, k)
+		}
+
+		for k := range new_map {
+
+			if _, ok := old_map[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+
+			k_path := k
+
+			if path != "" {
+				k_path = fmt.Sprintf("%s.%s", path, k)
+			}
+
+			compareValues(k_path, old_map[k], new_map[k], changes)
+		}
+
+		return
+	}
+
+	old_list, old_ok := old_value.([]interface{})
+	new_list, new_ok := new_value.([]interface{})
+
+	if old_ok && new_ok {
+
+		count := len(old_list)
+
+		if len(new_list) > count {
+			count = len(new_list)
+		}
+
+		for i := 0; i < count; i++ {
+
+			var old_item interface{}
+			var new_item interface{}
+
+			if i < len(old_list) {
+				old_item = old_list[i]
+			}
+
+			if i < len(new_list) {
+				new_item = new_list[i]
+			}
+
+			compareValues(fmt.Sprintf("%s[%d]", path, i), old_item, new_item, changes)
+		}
+
+		return
+	}
+
+	if reflect.DeepEqual(old_value, new_value) {
+		return
+	}
+
+	c := &FieldChange{
+		Path: path,
+		Old:  old_value,
+		New:  new_value,
+	}
+
+	*changes = append(*changes, c)
+}
@@ -0,0 +1,200 @@
+// package diff provides methods for comparing two snapshots (releases) of the OpenAccess dataset.
+package diff
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
+	"sort"
+	"sync"
+)
+
+const CHANGE_ADDED string = "added"
+
+const CHANGE_REMOVED string = "removed"
+
+const CHANGE_MODIFIED string = "modified"
+
+const CHANGE_UNCHANGED string = "unchanged"
+
+// Entry is the location and signature of a record in a snapshot.
+
+type Entry struct {
+	Id         string `json:"id"`
+	Unit       string `json:"unit"`
+	Path       string `json:"path"`
+	LineNumber int    `json:"line_number"`
+	// The record's `hash` property.
+	Hash string `json:"hash,omitempty"`
+	// The record's `docSignature` property.
+	DocSignature string `json:"doc_signature,omitempty"`
+	// The MD5 hash of the record's body. This is only set if the record has neither a `hash` or `docSignature` property.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// NewEntry returns a new Entry instance for rec.
+
+func NewEntry(rec *walk.Record) *Entry {
+
+	object := rec.OpenAccessRecord
+
+	e := &Entry{
+		Id:           object.Id,
+		Unit:         object.UnitCode,
+		Path:         rec.Path,
+		LineNumber:   rec.LineNumber,
+		Hash:         object.Hash,
+		DocSignature: object.DocSignature,
+	}
+
+	if e.Hash == "" && e.DocSignature == "" {
+		sum := md5.Sum(rec.Body)
+		e.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	return e
+}
+
+// Equals returns a boolean value indicating whether e and other have the same signature.
+
+func (e *Entry) Equals(other *Entry) bool {
+	return e.Hash == other.Hash && e.DocSignature == other.DocSignature && e.Checksum == other.Checksum
+}
+
+// Snapshot is an index of the entries, keyed by `id`, in a snapshot of the OpenAccess dataset. It also keeps
+// track of which entries have been compared with another snapshot.
+
+type Snapshot struct {
+	entries map[string]*Entry
+	seen    map[string]bool
+	mu      *sync.RWMutex
+}
+
+// NewSnapshot returns a new, empty, Snapshot instance.
+
+func NewSnapshot() *Snapshot {
+
+	s := &Snapshot{
+		entries: make(map[string]*Entry),
+		seen:    make(map[string]bool),
+		mu:      new(sync.RWMutex),
+	}
+
+	return s
+}
+
+// IndexSnapshot returns a new Snapshot instance containing an entry for every record found by walking bucket
+// according to opts. The opts.Callback and opts.RecordCallback properties are ignored.
+
+func IndexSnapshot(ctx context.Context, opts *walk.WalkOptions, bucket *blob.Bucket) (*Snapshot, error) {
+
+	s := NewSnapshot()
+
+	cb := func(ctx context.Context, rec *walk.Record, err error) error {
+
+		if err != nil {
+
+			if jw.IsEOFError(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		s.Add(NewEntry(rec))
+		return nil
+	}
+
+	walk_opts := *opts
+	walk_opts.RecordCallback = cb
+
+	err := walk.WalkBucket(ctx, &walk_opts, bucket)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Add adds e to s. If an entry with the same `id` already exists it is replaced.
+
+func (s *Snapshot) Add(e *Entry) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[e.Id] = e
+}
+
+// Get returns the entry for id.
+
+func (s *Snapshot) Get(id string) (*Entry, bool) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[id]
+	return e, ok
+}
+
+// Count returns the number of entries in s.
+
+func (s *Snapshot) Count() int {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.entries)
+}
+
+// Compare compares e, from another snapshot, with the entry in s that has the same `id` and returns one of the
+// CHANGE_ADDED, CHANGE_MODIFIED or CHANGE_UNCHANGED constants and the entry in s (if present). The entry in s is
+// marked as seen.
+
+func (s *Snapshot) Compare(e *Entry) (string, *Entry) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.entries[e.Id]
+
+	if !ok {
+		return CHANGE_ADDED, nil
+	}
+
+	s.seen[e.Id] = true
+
+	if existing.Equals(e) {
+		return CHANGE_UNCHANGED, existing
+	}
+
+	return CHANGE_MODIFIED, existing
+}
+
+// Unseen returns the entries in s, sorted by `id`, that have not been marked as seen by Compare. After comparing
+// all the records in a newer snapshot these are the records that have been removed.
+
+func (s *Snapshot) Unseen() []*Entry {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]*Entry, 0)
+
+	for id, e := range s.entries {
+
+		if !s.seen[id] {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Id < entries[j].Id
+	})
+
+	return entries
+}
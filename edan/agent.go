@@ -0,0 +1,114 @@
+package edan
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Agent is a person or organization parsed from a `freetext.name` (or `freetext.manufacturer`) value.
+
+type Agent struct {
+	// The original content string.
+	Raw string `json:"raw"`
+	// The display name of the agent, for example "Charles Nicolas Ransonnette".
+	Name string `json:"name"`
+	// The role of the agent derived from the value's label, for example "Artist" or "Manufacturer".
+	Role string `json:"role,omitempty"`
+	// The nationality of the agent, for example "French".
+	Nationality string `json:"nationality,omitempty"`
+	// The agent's birth year or 0 if unknown.
+	BirthYear int `json:"birth_year,omitempty"`
+	// The agent's death year or 0 if unknown.
+	DeathYear int `json:"death_year,omitempty"`
+}
+
+var re_agent_lifespan *regexp.Regexp
+var re_agent_born *regexp.Regexp
+var re_agent_died *regexp.Regexp
+var re_agent_active *regexp.Regexp
+
+func init() {
+	re_agent_lifespan = regexp.MustCompile(`^(?:ca\.\s*|c\.\s*)?(\d{4})\s*[-–]\s*(?:ca\.\s*|c\.\s*)?(\d{4})$`)
+	re_agent_born = regexp.MustCompile(`^(?i:born|b\.)\s*(\d{4})$`)
+	re_agent_died = regexp.MustCompile(`^(?i:died|d\.)\s*(\d{4})$`)
+	re_agent_active = regexp.MustCompile(`^(?i:active|fl\.)\s+.*\d{4}`)
+}
+
+// ParseAgent parses a `freetext.name` content string and its label in to an Agent. Strings in the form of
+// "{NAME}, {NATIONALITY}, {BIRTH} - {DEATH}" are split in to their component parts. The nationality is always the
+// segment immediately before the life dates so names containing commas (for example "Smith, John") are kept intact. Strings that do not contain
+// any recognizable life dates (for example "Clerget, Blin and Cie") are assumed to be a name in their entirety.
+
+func ParseAgent(content string, label string) *Agent {
+
+	content = strings.TrimSpace(content)
+
+	a := &Agent{
+		Raw:  content,
+		Name: content,
+		Role: strings.TrimSpace(label),
+	}
+
+	parts := strings.Split(content, ",")
+
+	if len(parts) < 2 {
+		return a
+	}
+
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+
+	// The index of the first segment containing life dates
+
+	dates_idx := -1
+
+	for i, p := range parts[1:] {
+
+		switch {
+		case re_agent_lifespan.MatchString(p):
+
+			m := re_agent_lifespan.FindStringSubmatch(p)
+			a.BirthYear, _ = strconv.Atoi(m[1])
+			a.DeathYear, _ = strconv.Atoi(m[2])
+
+		case re_agent_born.MatchString(p):
+
+			m := re_agent_born.FindStringSubmatch(p)
+			a.BirthYear, _ = strconv.Atoi(m[1])
+
+		case re_agent_died.MatchString(p):
+
+			m := re_agent_died.FindStringSubmatch(p)
+			a.DeathYear, _ = strconv.Atoi(m[1])
+
+		case re_agent_active.MatchString(p):
+			// pass
+
+		default:
+			continue
+		}
+
+		if dates_idx == -1 {
+			dates_idx = i + 1
+		}
+	}
+
+	if dates_idx == -1 {
+		return a
+	}
+
+	// Names may themselves contain commas (for example "Smith, John, American, 1900 - 1980") so only
+	// the segment immediately before the life dates is considered to be the nationality
+
+	if dates_idx == 1 {
+		a.Name = parts[0]
+		return a
+	}
+
+	a.Name = strings.Join(parts[0:dates_idx-1], ", ")
+	a.Nationality = parts[dates_idx-1]
+
+	return a
+}
@@ -0,0 +1,50 @@
+package edan
+
+import (
+	"testing"
+)
+
+func TestParseAgent(t *testing.T) {
+
+	tests := []struct {
+		content     string
+		name        string
+		nationality string
+		birth       int
+		death       int
+	}{
+		{"Charles Nicolas Ransonnette, French, 1793 - 1877", "Charles Nicolas Ransonnette", "French", 1793, 1877},
+		{"Smith, John, American, 1900-1980", "Smith, John", "American", 1900, 1980},
+		{"Smith, John, American, born 1900", "Smith, John", "American", 1900, 0},
+		{"Lucienne Day, British, born 1917, died 2010", "Lucienne Day", "British", 1917, 2010},
+		{"Wright Aeronautical Corporation, 1919-1929", "Wright Aeronautical Corporation", "", 1919, 1929},
+		{"Jane Doe, American, active 1920s", "Jane Doe", "American", 0, 0},
+		{"Clerget, Blin and Cie", "Clerget, Blin and Cie", "", 0, 0},
+		{"Cooper Hewitt", "Cooper Hewitt", "", 0, 0},
+	}
+
+	for _, test := range tests {
+
+		a := ParseAgent(test.content, "Artist")
+
+		if a.Raw != test.content {
+			t.Errorf("Expected raw value '%s' but got '%s'", test.content, a.Raw)
+		}
+
+		if a.Name != test.name {
+			t.Errorf("Expected name '%s' for '%s' but got '%s'", test.name, test.content, a.Name)
+		}
+
+		if a.Nationality != test.nationality {
+			t.Errorf("Expected nationality '%s' for '%s' but got '%s'", test.nationality, test.content, a.Nationality)
+		}
+
+		if a.BirthYear != test.birth || a.DeathYear != test.death {
+			t.Errorf("Expected life dates %d-%d for '%s' but got %d-%d", test.birth, test.death, test.content, a.BirthYear, a.DeathYear)
+		}
+
+		if a.Role != "Artist" {
+			t.Errorf("Expected role 'Artist' for '%s' but got '%s'", test.content, a.Role)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package edan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The `type` property of an OpenAccess record signals the shape of its `content` property.
+// https://edan.si.edu/openaccess/docs/more.html
+
+const EDANMDM string = "edanmdm"
+
+const EAD_COLLECTION string = "ead_collection"
+
+const EAD_COMPONENT string = "ead_component"
+
+const EVENT string = "event"
+
+// NewContentRecordFunc returns a new (empty) pointer that a record's `content` property will be decoded in to.
+
+type NewContentRecordFunc func() interface{}
+
+var content_types map[string]NewContentRecordFunc
+
+var content_types_mu *sync.RWMutex
+
+func init() {
+
+	content_types = make(map[string]NewContentRecordFunc)
+	content_types_mu = new(sync.RWMutex)
+
+	RegisterContentType(EDANMDM, func() interface{} { return new(IIMObjectRecord) })
+	RegisterContentType(EAD_COLLECTION, func() interface{} { return new(EADRecord) })
+	RegisterContentType(EAD_COMPONENT, func() interface{} { return new(EADRecord) })
+	RegisterContentType(EVENT, func() interface{} { return new(EventRecord) })
+}
+
+// RegisterContentType associates a record type (for example "edanmdm") with a function for creating
+// the struct its content will be decoded in to. It is an error to register the same type twice.
+
+func RegisterContentType(t string, fn NewContentRecordFunc) error {
+
+	content_types_mu.Lock()
+	defer content_types_mu.Unlock()
+
+	_, exists := content_types[t]
+
+	if exists {
+		return fmt.Errorf("Content type '%s' is already registered", t)
+	}
+
+	content_types[t] = fn
+	return nil
+}
+
+// IsRegisteredContentType returns a boolean value indicating whether a record type has been registered.
+
+func IsRegisteredContentType(t string) bool {
+
+	content_types_mu.RLock()
+	defer content_types_mu.RUnlock()
+
+	_, exists := content_types[t]
+	return exists
+}
+
+// ContentTypes returns the sorted list of registered record types.
+
+func ContentTypes() []string {
+
+	content_types_mu.RLock()
+	defer content_types_mu.RUnlock()
+
+	types := make([]string, 0)
+
+	for t := range content_types {
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+// UnmarshalContent decodes body in to the struct registered for the record type t. Types
+// that have not been registered are decoded as a *GenericRecord rather than failing.
+
+func UnmarshalContent(t string, body []byte) (interface{}, error) {
+
+	content_types_mu.RLock()
+	fn, exists := content_types[t]
+	content_types_mu.RUnlock()
+
+	if !exists {
+
+		var generic GenericRecord
+		err := json.Unmarshal(body, &generic)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal '%s' content as generic record, %w", t, err)
+		}
+
+		return &generic, nil
+	}
+
+	content := fn()
+
+	err := json.Unmarshal(body, content)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal '%s' content, %w", t, err)
+	}
+
+	return content, nil
+}
+
+// EADRecord is the content of finding-aid style (Encoded Archival Description) records published by
+// archival units like AAA and SIA. These are collections, or components of collections, rather than individual
+// objects. They share the descriptive and indexed blocks of object records but their free text uses a different,
+// open-ended, set of categories.
+
+type EADRecord struct {
+	DescriptiveNonRepeating IIMDescriptiveNonRepeating `json:"descriptiveNonRepeating,omitempty"`
+	FreeText                EADFreeText                `json:"freetext,omitempty"`
+	IndexedStructured       IIMIndexedStructured       `json:"indexedStructured,omitempty"`
+}
+
+// EADFreeText is the `freetext` block of an EADRecord, keyed by category.
+
+type EADFreeText map[string][]IIMContentLabel
+
+// Get returns the content of every entry in category whose label is label. If label is empty the content
+// of every entry in category is returned.
+
+func (t EADFreeText) Get(category string, label string) []string {
+
+	values := make([]string, 0)
+
+	for _, e := range t[category] {
+
+		if label != "" && e.Label != label {
+			continue
+		}
+
+		values = append(values, e.Content)
+	}
+
+	return values
+}
+
+// EventRecord is the content of event records. Other than the descriptive block they share with object
+// records the properties of events are not documented, and their free text values are not necessarily
+// lists of labelled content, so they are kept as raw JSON.
+
+type EventRecord struct {
+	DescriptiveNonRepeating IIMDescriptiveNonRepeating `json:"descriptiveNonRepeating,omitempty"`
+	FreeText                map[string]json.RawMessage `json:"freetext,omitempty"`
+	IndexedStructured       map[string]json.RawMessage `json:"indexedStructured,omitempty"`
+}
+
+// GenericRecord is the content of any record whose type has not been registered. Each
+// top-level property is kept as raw JSON so that it can be re-encoded verbatim.
+
+type GenericRecord map[string]json.RawMessage
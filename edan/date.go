@@ -0,0 +1,430 @@
+package edan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DATE_EXACT signals that a date string was parsed without any qualifiers.
+
+const DATE_EXACT string = "exact"
+
+// DATE_APPROXIMATE signals that a date string was qualified as approximate, for example "Circa 1933".
+
+const DATE_APPROXIMATE string = "approximate"
+
+// DATE_UNCERTAIN signals that a date string was qualified as uncertain, for example "1933?" or "probably 1933".
+
+const DATE_UNCERTAIN string = "uncertain"
+
+// DATE_INFERRED signals that a date string didn't match any known pattern and its range was inferred
+// from the years it contains, for example "May 12, 1933".
+
+const DATE_INFERRED string = "inferred"
+
+// DateRange is the normalized form of a free-text date string.
+
+type DateRange struct {
+	// The original date string.
+	Raw string `json:"raw"`
+	// The earliest year the date string may refer to.
+	Earliest int `json:"earliest"`
+	// The latest year the date string may refer to.
+	Latest int `json:"latest"`
+	// An Extended Date/Time Format (EDTF) representation of the date string.
+	EDTF string `json:"edtf"`
+	// One of DATE_EXACT, DATE_APPROXIMATE, DATE_UNCERTAIN or DATE_INFERRED.
+	Qualifier string `json:"qualifier"`
+}
+
+// Overlaps returns a boolean value indicating whether r overlaps the years earliest to latest (inclusive).
+
+func (r *DateRange) Overlaps(earliest int, latest int) bool {
+	return r.Earliest <= latest && r.Latest >= earliest
+}
+
+var re_date_decade *regexp.Regexp
+var re_date_decade_range *regexp.Regexp
+var re_date_century_s *regexp.Regexp
+var re_date_century_s_range *regexp.Regexp
+var re_date_year_decade_range *regexp.Regexp
+var re_date_decade_year_range *regexp.Regexp
+var re_date_century *regexp.Regexp
+var re_date_range *regexp.Regexp
+var re_date_year *regexp.Regexp
+var re_date_ymd *regexp.Regexp
+var re_date_ym *regexp.Regexp
+var re_date_any_year *regexp.Regexp
+var re_date_approximate *regexp.Regexp
+var re_date_uncertain *regexp.Regexp
+var re_date_bce *regexp.Regexp
+
+func init() {
+	re_date_decade = regexp.MustCompile(`^(\d{2,3})0s$`)
+	re_date_decade_range = regexp.MustCompile(`^(\d{2,3})0s\s*(?:-|/|to)\s*(\d{1,3})0s$`)
+	re_date_century_s = regexp.MustCompile(`^(\d{1,2})00s$`)
+	re_date_century_s_range = regexp.MustCompile(`^(\d{1,2})00s\s*(?:-|/|to)\s*(\d{1,2})00s$`)
+	re_date_year_decade_range = regexp.MustCompile(`^(\d{3})\d\s*(?:-|/|to)\s*(\d{1,3})0s$`)
+	re_date_decade_year_range = regexp.MustCompile(`^(\d{2,3})0s\s*(?:-|/|to)\s*(\d{1,4})$`)
+	re_date_century = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)\s+century$`)
+	re_date_range = regexp.MustCompile(`^(\d{1,4})\s*(?:-|/|to)\s*(\d{1,4})$`)
+	re_date_year = regexp.MustCompile(`^(\d{1,4})$`)
+	re_date_ymd = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	re_date_ym = regexp.MustCompile(`^(\d{4})-(0[1-9]|1[0-2])$`)
+	re_date_any_year = regexp.MustCompile(`\b(\d{4})\b`)
+	re_date_approximate = regexp.MustCompile(`^(?:circa|ca\.?|c\.|about|approximately|approx\.)\s*`)
+	re_date_uncertain = regexp.MustCompile(`^(?:probably|possibly|perhaps)\s+`)
+	re_date_bce = regexp.MustCompile(`\s*(?:bce|b\.c\.e\.|bc|b\.c\.)$`)
+}
+
+// ParseDate parses a free-text date string, like those found in `freetext.date` and `indexedStructured.date`,
+// in to a DateRange. Supported patterns are single years ("1825"), ranges ("1825–1840", "1825-40", "1825 to 1840"),
+// decades ("1840s"), ranges of decades ("1920s-1930s", "1920s-30s") or of years and decades ("1910-1920s", "1920s-35"),
+// centuries ("19th century", "1800s") and ISO-8601 dates
+// ("1933-05-12", "1933-05"), optionally qualified as approximate ("Circa 1933") or uncertain ("1933?") or suffixed
+// with "BCE". Abbreviated ranges are only recognized if they end after they start so "1825-40" is the range 1825 to
+// 1840 but "1933-05" is May 1933. Anything else containing one or more four-digit years is returned as a
+// DATE_INFERRED range spanning those years.
+
+func ParseDate(raw string) (*DateRange, error) {
+
+	s := strings.ToLower(strings.TrimSpace(raw))
+
+	s = strings.Replace(s, "–", "-", -1) // en dash
+	s = strings.Replace(s, "—", "-", -1) // em dash
+
+	qualifier := DATE_EXACT
+	edtf_suffix := ""
+
+	if re_date_approximate.MatchString(s) {
+		s = re_date_approximate.ReplaceAllString(s, "")
+		qualifier = DATE_APPROXIMATE
+		edtf_suffix = "~"
+	}
+
+	if re_date_uncertain.MatchString(s) {
+		s = re_date_uncertain.ReplaceAllString(s, "")
+		qualifier = DATE_UNCERTAIN
+		edtf_suffix = "?"
+	}
+
+	if strings.HasSuffix(s, "?") {
+		s = strings.TrimSpace(strings.TrimRight(s, "?"))
+		qualifier = DATE_UNCERTAIN
+		edtf_suffix = "?"
+	}
+
+	bce := false
+
+	if re_date_bce.MatchString(s) {
+		s = re_date_bce.ReplaceAllString(s, "")
+		bce = true
+	}
+
+	r := &DateRange{
+		Raw:       raw,
+		Qualifier: qualifier,
+	}
+
+	switch {
+	case re_date_century_s.MatchString(s) && !bce:
+
+		// "1800s" almost always means the century rather than the decade 1800 to 1809
+
+		m := re_date_century_s.FindStringSubmatch(s)
+		prefix, _ := strconv.Atoi(m[1])
+
+		r.Earliest = prefix * 100
+		r.Latest = r.Earliest + 99
+		r.EDTF = fmt.Sprintf("%02dXX%s", prefix, edtf_suffix)
+
+	case re_date_century_s_range.MatchString(s) && !bce:
+
+		m := re_date_century_s_range.FindStringSubmatch(s)
+
+		start, _ := strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+
+		if start > end {
+			start, end = end, start
+		}
+
+		r.Earliest = start * 100
+		r.Latest = end*100 + 99
+		r.EDTF = fmt.Sprintf("%02dXX%s/%02dXX%s", start, edtf_suffix, end, edtf_suffix)
+
+	case re_date_decade.MatchString(s) && !bce:
+
+		m := re_date_decade.FindStringSubmatch(s)
+		prefix, _ := strconv.Atoi(m[1])
+
+		r.Earliest = prefix * 10
+		r.Latest = r.Earliest + 9
+		r.EDTF = fmt.Sprintf("%03dX%s", prefix, edtf_suffix)
+
+	case re_date_decade_range.MatchString(s) && !bce:
+
+		m := re_date_decade_range.FindStringSubmatch(s)
+
+		start, end, ok := expandRange(m[1], m[2], false)
+
+		if !ok {
+			return nil, fmt.Errorf("Invalid range of decades in date string '%s'", raw)
+		}
+
+		if start > end {
+			start, end = end, start
+		}
+
+		r.Earliest = start * 10
+		r.Latest = end*10 + 9
+		r.EDTF = fmt.Sprintf("%03dX%s/%03dX%s", start, edtf_suffix, end, edtf_suffix)
+
+	case re_date_year_decade_range.MatchString(s) && !bce:
+
+		// For example "1910-1920s" or "1910-20s"
+
+		m := re_date_year_decade_range.FindStringSubmatch(s)
+
+		start_decade, end_decade, ok := expandRange(m[1], m[2], false)
+
+		if !ok || end_decade < start_decade {
+			return nil, fmt.Errorf("Invalid range of years in date string '%s'", raw)
+		}
+
+		start, _ := strconv.Atoi(s[0:4])
+
+		r.Earliest = start
+		r.Latest = end_decade*10 + 9
+		r.EDTF = fmt.Sprintf("%04d%s/%03dX%s", start, edtf_suffix, end_decade, edtf_suffix)
+
+	case re_date_decade_year_range.MatchString(s) && !bce:
+
+		// For example "1920s-1935" or "1920s-35"
+
+		m := re_date_decade_year_range.FindStringSubmatch(s)
+
+		start_decade, _ := strconv.Atoi(m[1])
+		_, end, ok := expandRange(m[1]+"0", m[2], false)
+
+		if !ok || end < start_decade*10 {
+			return nil, fmt.Errorf("Invalid range of years in date string '%s'", raw)
+		}
+
+		r.Earliest = start_decade * 10
+		r.Latest = end
+		r.EDTF = fmt.Sprintf("%03dX%s/%04d%s", start_decade, edtf_suffix, end, edtf_suffix)
+
+	case re_date_century.MatchString(s) && !bce:
+
+		m := re_date_century.FindStringSubmatch(s)
+		century, _ := strconv.Atoi(m[1])
+
+		if century == 0 {
+			return nil, fmt.Errorf("Invalid century in date string '%s'", raw)
+		}
+
+		r.Earliest = (century - 1) * 100
+		r.Latest = r.Earliest + 99
+		r.EDTF = fmt.Sprintf("%02dXX%s", century-1, edtf_suffix)
+
+	case re_date_ymd.MatchString(s):
+
+		m := re_date_ymd.FindStringSubmatch(s)
+		year, _ := strconv.Atoi(m[1])
+
+		r.Earliest = signedYear(year, bce)
+		r.Latest = r.Earliest
+		r.EDTF = fmt.Sprintf("%s-%s-%s%s", formatYear(r.Earliest), m[2], m[3], edtf_suffix)
+
+	case re_date_range.MatchString(s) && isDateRange(s, bce):
+
+		m := re_date_range.FindStringSubmatch(s)
+
+		start, end, _ := expandRange(m[1], m[2], bce)
+
+		start = signedYear(start, bce)
+		end = signedYear(end, bce)
+
+		if start > end {
+			start, end = end, start
+		}
+
+		r.Earliest = start
+		r.Latest = end
+		r.EDTF = fmt.Sprintf("%s%s/%s%s", formatYear(start), edtf_suffix, formatYear(end), edtf_suffix)
+
+	case re_date_ym.MatchString(s):
+
+		m := re_date_ym.FindStringSubmatch(s)
+		year, _ := strconv.Atoi(m[1])
+
+		r.Earliest = signedYear(year, bce)
+		r.Latest = r.Earliest
+		r.EDTF = fmt.Sprintf("%s-%s%s", formatYear(r.Earliest), m[2], edtf_suffix)
+
+	case re_date_year.MatchString(s):
+
+		year, _ := strconv.Atoi(s)
+
+		r.Earliest = signedYear(year, bce)
+		r.Latest = r.Earliest
+		r.EDTF = fmt.Sprintf("%s%s", formatYear(r.Earliest), edtf_suffix)
+
+	default:
+
+		m := re_date_any_year.FindAllStringSubmatch(s, -1)
+
+		if len(m) == 0 {
+			return nil, fmt.Errorf("Unable to parse date string '%s'", raw)
+		}
+
+		for i, match := range m {
+
+			year, _ := strconv.Atoi(match[1])
+			year = signedYear(year, bce)
+
+			if i == 0 || year < r.Earliest {
+				r.Earliest = year
+			}
+
+			if i == 0 || year > r.Latest {
+				r.Latest = year
+			}
+		}
+
+		r.Qualifier = DATE_INFERRED
+
+		if r.Earliest == r.Latest {
+			r.EDTF = fmt.Sprintf("%s?", formatYear(r.Earliest))
+		} else {
+			r.EDTF = fmt.Sprintf("%s?/%s?", formatYear(r.Earliest), formatYear(r.Latest))
+		}
+	}
+
+	return r, nil
+}
+
+// UnionDateRanges returns a single DateRange spanning the earliest and latest years of ranges. The
+// qualifier of the union is the least certain qualifier of any of its members.
+
+func UnionDateRanges(ranges ...*DateRange) (*DateRange, error) {
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("No date ranges to union")
+	}
+
+	if len(ranges) == 1 {
+		return ranges[0], nil
+	}
+
+	raw := make([]string, len(ranges))
+
+	u := &DateRange{
+		Earliest:  ranges[0].Earliest,
+		Latest:    ranges[0].Latest,
+		Qualifier: DATE_EXACT,
+	}
+
+	for i, r := range ranges {
+
+		raw[i] = r.Raw
+
+		if r.Earliest < u.Earliest {
+			u.Earliest = r.Earliest
+		}
+
+		if r.Latest > u.Latest {
+			u.Latest = r.Latest
+		}
+
+		if qualifierRank(r.Qualifier) > qualifierRank(u.Qualifier) {
+			u.Qualifier = r.Qualifier
+		}
+	}
+
+	u.Raw = strings.Join(raw, "; ")
+
+	edtf_suffix := ""
+
+	switch u.Qualifier {
+	case DATE_APPROXIMATE:
+		edtf_suffix = "~"
+	case DATE_UNCERTAIN, DATE_INFERRED:
+		edtf_suffix = "?"
+	default:
+		// pass
+	}
+
+	u.EDTF = fmt.Sprintf("%s%s/%s%s", formatYear(u.Earliest), edtf_suffix, formatYear(u.Latest), edtf_suffix)
+	return u, nil
+}
+
+func qualifierRank(q string) int {
+
+	switch q {
+	case DATE_APPROXIMATE:
+		return 1
+	case DATE_UNCERTAIN:
+		return 2
+	case DATE_INFERRED:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// isDateRange returns a boolean value indicating whether s, which must match re_date_range, is a valid range of years.
+
+func isDateRange(s string, bce bool) bool {
+
+	m := re_date_range.FindStringSubmatch(s)
+
+	_, _, ok := expandRange(m[1], m[2], bce)
+	return ok
+}
+
+// expandRange returns the start and end of the range start_str to end_str. If end_str has fewer digits than start_str
+// it is abbreviated (for example "1825-40") and is expanded using the leading digits of start_str. Abbreviated ranges
+// must end after they start (or before they start if bce is true) otherwise the boolean value returned is false.
+
+func expandRange(start_str string, end_str string, bce bool) (int, int, bool) {
+
+	start, _ := strconv.Atoi(start_str)
+	end, _ := strconv.Atoi(end_str)
+
+	if len(end_str) >= len(start_str) {
+		return start, end, true
+	}
+
+	prefix := start_str[:len(start_str)-len(end_str)]
+	end, _ = strconv.Atoi(prefix + end_str)
+
+	if bce {
+		return start, end, end <= start
+	}
+
+	return start, end, end >= start
+}
+
+func signedYear(year int, bce bool) int {
+
+	if bce {
+		return -year
+	}
+
+	return year
+}
+
+// EDTF years are zero-padded to four digits; negative years are prefixed with "-".
+
+func formatYear(year int) string {
+
+	if year < 0 {
+		return fmt.Sprintf("-%04d", -year)
+	}
+
+	return fmt.Sprintf("%04d", year)
+}
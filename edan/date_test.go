@@ -0,0 +1,97 @@
+package edan
+
+import (
+	"testing"
+)
+
+func TestParseDate(t *testing.T) {
+
+	tests := []struct {
+		raw       string
+		earliest  int
+		latest    int
+		edtf      string
+		qualifier string
+	}{
+		{"1825", 1825, 1825, "1825", DATE_EXACT},
+		{"1825-1840", 1825, 1840, "1825/1840", DATE_EXACT},
+		{"1825–1840", 1825, 1840, "1825/1840", DATE_EXACT},
+		{"1840-1825", 1825, 1840, "1825/1840", DATE_EXACT},
+		{"1825-40", 1825, 1840, "1825/1840", DATE_EXACT},
+		{"1825-5", 1825, 1825, "1825/1825", DATE_EXACT},
+		{"1899-1905", 1899, 1905, "1899/1905", DATE_EXACT},
+		{"1899-05", 1899, 1899, "1899-05", DATE_EXACT},
+		{"1825 to 1840", 1825, 1840, "1825/1840", DATE_EXACT},
+		{"1933-05", 1933, 1933, "1933-05", DATE_EXACT},
+		{"1933-12", 1933, 1933, "1933-12", DATE_EXACT},
+		{"1901-05", 1901, 1905, "1901/1905", DATE_EXACT},
+		{"1933-05-12", 1933, 1933, "1933-05-12", DATE_EXACT},
+		{"1840s", 1840, 1849, "184X", DATE_EXACT},
+		{"1920s-1930s", 1920, 1939, "192X/193X", DATE_EXACT},
+		{"1920s - 1930s", 1920, 1939, "192X/193X", DATE_EXACT},
+		{"1920s-30s", 1920, 1939, "192X/193X", DATE_EXACT},
+		{"1890s-1910s", 1890, 1919, "189X/191X", DATE_EXACT},
+		{"19th century", 1800, 1899, "18XX", DATE_EXACT},
+		{"1800s", 1800, 1899, "18XX", DATE_EXACT},
+		{"circa 1900s", 1900, 1999, "19XX~", DATE_APPROXIMATE},
+		{"1800s-1900s", 1800, 1999, "18XX/19XX", DATE_EXACT},
+		{"1810s", 1810, 1819, "181X", DATE_EXACT},
+		{"1910-1920s", 1910, 1929, "1910/192X", DATE_EXACT},
+		{"1910-20s", 1910, 1929, "1910/192X", DATE_EXACT},
+		{"1915-1910s", 1915, 1919, "1915/191X", DATE_EXACT},
+		{"1920s-1935", 1920, 1935, "192X/1935", DATE_EXACT},
+		{"1920s-35", 1920, 1935, "192X/1935", DATE_EXACT},
+		{"Circa 1933", 1933, 1933, "1933~", DATE_APPROXIMATE},
+		{"ca. 1920s-1930s", 1920, 1939, "192X~/193X~", DATE_APPROXIMATE},
+		{"1933?", 1933, 1933, "1933?", DATE_UNCERTAIN},
+		{"probably 1933-05", 1933, 1933, "1933-05?", DATE_UNCERTAIN},
+		{"500 BCE", -500, -500, "-0500", DATE_EXACT},
+		{"525-10 BC", -525, -510, "-0525/-0510", DATE_EXACT},
+		{"May 12, 1933", 1933, 1933, "1933?", DATE_INFERRED},
+		{"1933-13", 1933, 1933, "1933?", DATE_INFERRED},
+		{"designed 1925, made 1930", 1925, 1930, "1925?/1930?", DATE_INFERRED},
+	}
+
+	for _, test := range tests {
+
+		r, err := ParseDate(test.raw)
+
+		if err != nil {
+			t.Errorf("Failed to parse '%s', %v", test.raw, err)
+			continue
+		}
+
+		if r.Earliest != test.earliest || r.Latest != test.latest {
+			t.Errorf("Expected '%s' to span %d to %d but got %d to %d", test.raw, test.earliest, test.latest, r.Earliest, r.Latest)
+		}
+
+		if r.EDTF != test.edtf {
+			t.Errorf("Expected EDTF '%s' for '%s' but got '%s'", test.edtf, test.raw, r.EDTF)
+		}
+
+		if r.Qualifier != test.qualifier {
+			t.Errorf("Expected qualifier '%s' for '%s' but got '%s'", test.qualifier, test.raw, r.Qualifier)
+		}
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+
+	tests := []string{
+		"",
+		"undated",
+		"0th century",
+		"1930s-20s",
+		"1930-20s",
+		"1930s-1925",
+	}
+
+	for _, raw := range tests {
+
+		_, err := ParseDate(raw)
+
+		if err == nil {
+			t.Errorf("Expected '%s' to fail to parse", raw)
+		}
+	}
+}
@@ -9,14 +9,17 @@ package edan
 type IIMObjectRecord struct {
 	DescriptiveNonRepeating IIMDescriptiveNonRepeating `json:"descriptiveNonRepeating,omitempty"`
 	FreeText                IIMFreeText                `json:"freetext,omitempty"`
-	IndexedStructured       IIMIndexedStructured       `json:"indexStructured,omitempty"`
+	IndexedStructured       IIMIndexedStructured       `json:"indexedStructured,omitempty"`
 }
 
 type IIMUsage struct {
 	Access string `json:"access,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Codes  string `json:"codes,omitempty"`
 }
 
 type IIMMedia struct {
+	Caption   string             `json:"caption,omitempty"`
 	Content   string             `json:"content,omitempty"`
 	GUID      string             `json:"guid,omitempty"`
 	IDSId     string             `json:"idsId,omitempty"`
@@ -58,9 +61,13 @@ type IIMDescriptiveNonRepeating struct {
 
 type IIMFreeText struct {
 	CreditLine           []IIMContentLabel `json:"creditLine,omitempty"`
+	Culture              []IIMContentLabel `json:"culture,omitempty"`
 	DataSource           []IIMContentLabel `json:"dataSource,omitempty"`
 	Date                 []IIMContentLabel `json:"date,omitempty"`
+	Exhibition           []IIMContentLabel `json:"exhibition,omitempty"`
+	GeoLocation          []IIMContentLabel `json:"geoLocation,omitempty"`
 	Identifier           []IIMContentLabel `json:"identifier,omitempty"`
+	Language             []IIMContentLabel `json:"language,omitempty"`
 	Manufacturer         []IIMContentLabel `json:"manufacturer,omitempty"`
 	Name                 []IIMContentLabel `json:"name,omitempty"`
 	Notes                []IIMContentLabel `json:"notes,omitempty"`
@@ -68,7 +75,10 @@ type IIMFreeText struct {
 	ObjectType           []IIMContentLabel `json:"objectType,omitempty"`
 	PhysicalDescriptions []IIMContentLabel `json:"physicalDescription,omitempty"`
 	Place                []IIMContentLabel `json:"place,omitempty"`
+	Publisher            []IIMContentLabel `json:"publisher,omitempty"`
 	SetName              []IIMContentLabel `json:"setName,omitempty"`
+	TaxonomicName        []IIMContentLabel `json:"taxonomicName,omitempty"`
+	Topic                []IIMContentLabel `json:"topic,omitempty"`
 }
 
 type IIMGeoLocationLevel struct {
@@ -76,16 +86,76 @@ type IIMGeoLocationLevel struct {
 	Type    string `json:"type,omitempty"`
 }
 
+type IIMGeoLocationCoordinate struct {
+	Content string `json:"content,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+type IIMGeoLocationPoint struct {
+	Latitude  IIMGeoLocationCoordinate `json:"latitude,omitempty"`
+	Longitude IIMGeoLocationCoordinate `json:"longitude,omitempty"`
+}
+
+type IIMGeoLocationPoints struct {
+	Point IIMGeoLocationPoint `json:"point,omitempty"`
+}
+
+// L1 (continent) through L5 (city) are increasingly specific administrative levels. Other
+// is used for places (water bodies, sites, etc.) that don't fit the hierarchy.
+
 type IIMGeoLocation struct {
-	L2 IIMGeoLocationLevel `json:"L2,omitempty"`
+	L1     *IIMGeoLocationLevel  `json:"L1,omitempty"`
+	L2     *IIMGeoLocationLevel  `json:"L2,omitempty"`
+	L3     *IIMGeoLocationLevel  `json:"L3,omitempty"`
+	L4     *IIMGeoLocationLevel  `json:"L4,omitempty"`
+	L5     *IIMGeoLocationLevel  `json:"L5,omitempty"`
+	Other  *IIMGeoLocationLevel  `json:"Other,omitempty"`
+	Points *IIMGeoLocationPoints `json:"points,omitempty"`
+}
+
+type IIMExhibition struct {
+	Building        string `json:"building,omitempty"`
+	ExhibitionTitle string `json:"exhibitionTitle,omitempty"`
+	Room            string `json:"room,omitempty"`
+	UnitCode        string `json:"unitCode,omitempty"`
 }
 
 type IIMIndexedStructured struct {
-	Date            []string         `json:"date,omitempty"`
-	GeoLocation     []IIMGeoLocation `json:"geoLocation,omitempty"`
-	Name            []string         `json:"name,omitempty"`
-	ObjectType      []string         `json:"object_type,omitempty"`
-	OnlineMediaType []string         `json:"online_media_type,omitempty"`
-	Place           []string         `json:"place,omitempty"`
-	UsageFlag       []string         `json:"usage_flag,omitempty"`
+	CommonName        []string         `json:"common_name,omitempty"`
+	Culture           []string         `json:"culture,omitempty"`
+	Date              []string         `json:"date,omitempty"`
+	Exhibition        []IIMExhibition  `json:"exhibition,omitempty"`
+	GeoLocation       []IIMGeoLocation `json:"geoLocation,omitempty"`
+	Language          []string         `json:"language,omitempty"`
+	Name              []string         `json:"name,omitempty"`
+	ObjectType        []string         `json:"object_type,omitempty"`
+	OnlineMediaType   []string         `json:"online_media_type,omitempty"`
+	OnPhysicalExhibit []string         `json:"onPhysicalExhibit,omitempty"`
+	Place             []string         `json:"place,omitempty"`
+	ScientificName    []string         `json:"scientific_name,omitempty"`
+	StratEra          []string         `json:"strat_era,omitempty"`
+	StratGroup        []string         `json:"strat_group,omitempty"`
+	StratPeriod       []string         `json:"strat_period,omitempty"`
+	TaxClass          []string         `json:"tax_class,omitempty"`
+	TaxFamily         []string         `json:"tax_family,omitempty"`
+	TaxKingdom        []string         `json:"tax_kingdom,omitempty"`
+	TaxOrder          []string         `json:"tax_order,omitempty"`
+	TaxPhylum         []string         `json:"tax_phylum,omitempty"`
+	TaxSubFamily      []string         `json:"tax_sub-family,omitempty"`
+	Topic             []string         `json:"topic,omitempty"`
+	UsageFlag         []string         `json:"usage_flag,omitempty"`
+}
+
+// IsOnPhysicalExhibit returns a boolean value indicating whether the object is flagged as being on exhibit.
+
+func (s IIMIndexedStructured) IsOnPhysicalExhibit() bool {
+
+	for _, v := range s.OnPhysicalExhibit {
+
+		if v == "Yes" {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,84 @@
+package edan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIIMObjectRecordRoundTrip(t *testing.T) {
+
+	paths := make([]string, 0)
+
+	for _, pattern := range []string{"../fixtures/nasm/*.json", "../fixtures/chndm/*.json"} {
+
+		matches, err := filepath.Glob(pattern)
+
+		if err != nil {
+			t.Fatalf("Failed to glob %s, %v", pattern, err)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	if len(paths) == 0 {
+		t.Fatal("No fixtures found")
+	}
+
+	for _, path := range paths {
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+
+			body, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				t.Fatalf("Failed to read %s, %v", path, err)
+			}
+
+			var doc struct {
+				Content json.RawMessage `json:"content"`
+			}
+
+			err = json.Unmarshal(body, &doc)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal %s, %v", path, err)
+			}
+
+			var rec IIMObjectRecord
+
+			err = json.Unmarshal(doc.Content, &rec)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal content for %s, %v", path, err)
+			}
+
+			enc, err := json.Marshal(rec)
+
+			if err != nil {
+				t.Fatalf("Failed to marshal content for %s, %v", path, err)
+			}
+
+			var expected interface{}
+			var actual interface{}
+
+			err = json.Unmarshal(doc.Content, &expected)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal source content for %s, %v", path, err)
+			}
+
+			err = json.Unmarshal(enc, &actual)
+
+			if err != nil {
+				t.Fatalf("Failed to unmarshal encoded content for %s, %v", path, err)
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("Encoded content for %s does not match source, got %s", path, string(enc))
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// package findingaid provides methods for reading the CSV finding aids produced by the `findingaid` tool.
+package findingaid
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Entry is a single row in a finding aid mapping an identifier to the metadata file, and line number, that
+// contains its record.
+
+type Entry struct {
+	Id         string `json:"id"`
+	Path       string `json:"path"`
+	LineNumber int    `json:"line_number"`
+}
+
+// FindingAid is an in-memory index of finding aid entries keyed by identifier.
+
+type FindingAid struct {
+	entries map[string]*Entry
+}
+
+// NewFindingAid returns a new, empty, FindingAid.
+
+func NewFindingAid() *FindingAid {
+
+	fa := &FindingAid{
+		entries: make(map[string]*Entry),
+	}
+
+	return fa
+}
+
+// ReadFindingAid reads CSV data, in the form of `id,path,line_number`, or a compact finding aid index from r in to
+// a new FindingAid. An optional CSV header row is skipped.
+
+func ReadFindingAid(r io.Reader) (*FindingAid, error) {
+
+	fa := NewFindingAid()
+	br := bufio.NewReader(r)
+
+	var err error
+
+	if IsIndex(br) {
+		err = fa.ReadIndex(br)
+	} else {
+		err = fa.Read(br)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fa, nil
+}
+
+// Read reads CSV data, in the form of `id,path,line_number`, from r in to fa. An optional header row is skipped.
+
+func (fa *FindingAid) Read(r io.Reader) error {
+
+	csv_r := csv.NewReader(r)
+	csv_r.FieldsPerRecord = 3
+
+	for i := 0; ; i++ {
+
+		row, err := csv_r.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to read finding aid, %w", err)
+		}
+
+		lineno, err := strconv.Atoi(row[2])
+
+		if err != nil {
+
+			if i == 0 {
+				continue
+			}
+
+			return fmt.Errorf("Invalid line number for '%s', %w", row[0], err)
+		}
+
+		e := &Entry{
+			Id:         row[0],
+			Path:       row[1],
+			LineNumber: lineno,
+		}
+
+		fa.Add(e)
+	}
+
+	return nil
+}
+
+// Add adds e to fa, replacing any existing entry with the same identifier.
+
+func (fa *FindingAid) Add(e *Entry) {
+	fa.entries[e.Id] = e
+}
+
+// Get returns the entry for id. If id is an OpenAccess `id` (for example "edanmdm-nasm_A19710896000") and there
+// is no matching entry then Get will also try the corresponding `record_ID` (for example "nasm_A19710896000")
+// since that is what the `findingaid` tool emits by default.
+
+func (fa *FindingAid) Get(id string) (*Entry, error) {
+
+	e, ok := fa.entries[id]
+
+	if ok {
+		return e, nil
+	}
+
+	if strings.HasPrefix(id, "edanmdm-") {
+
+		e, ok = fa.entries[strings.TrimPrefix(id, "edanmdm-")]
+
+		if ok {
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Finding aid has no entry for '%s'", id)
+}
+
+// Count returns the number of entries in fa.
+
+func (fa *FindingAid) Count() int {
+	return len(fa.entries)
+}
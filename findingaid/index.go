@@ -0,0 +1,172 @@
+package findingaid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// INDEX_HEADER is the first line of a compact finding aid index.
+
+const INDEX_HEADER string = "#findingaid-index"
+
+// A compact finding aid index is a plain text alternative to the CSV finding aids that lists each path only once. For example:
+//
+//	#findingaid-index
+//	@metadata/edan/nasm/00.txt
+//	1	nasm_A19710896000	edanmdm-nasm_A19710896000
+//	2	nasm_A19720001000
+//
+// Lines starting with "@" are the path for all the lines that follow them. All other lines are a line number followed by
+// one or more identifiers, separated by tabs. Paths are sorted alphabetically and line numbers are sorted numerically.
+
+// IsIndex returns a boolean value indicating whether the data in r is a compact finding aid index. It does not consume r.
+
+func IsIndex(r *bufio.Reader) bool {
+
+	b, err := r.Peek(len(INDEX_HEADER))
+
+	if err != nil {
+		return false
+	}
+
+	return string(b) == INDEX_HEADER
+}
+
+// ReadIndex reads a compact finding aid index from r in to fa.
+
+func (fa *FindingAid) ReadIndex(r io.Reader) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	path := ""
+
+	for i := 1; scanner.Scan(); i++ {
+
+		ln := scanner.Text()
+
+		if i == 1 {
+
+			if ln != INDEX_HEADER {
+				return fmt.Errorf("Invalid finding aid index header")
+			}
+
+			continue
+		}
+
+		if ln == "" {
+			continue
+		}
+
+		if strings.HasPrefix(ln, "@") {
+			path = strings.TrimPrefix(ln, "@")
+			continue
+		}
+
+		if path == "" {
+			return fmt.Errorf("Invalid finding aid index, line %d has no path", i)
+		}
+
+		parts := strings.Split(ln, "\t")
+
+		if len(parts) < 2 {
+			return fmt.Errorf("Invalid finding aid index, line %d has no identifiers", i)
+		}
+
+		lineno, err := strconv.Atoi(parts[0])
+
+		if err != nil {
+			return fmt.Errorf("Invalid line number at line %d, %w", i, err)
+		}
+
+		for _, id := range parts[1:] {
+
+			e := &Entry{
+				Id:         id,
+				Path:       path,
+				LineNumber: lineno,
+			}
+
+			fa.Add(e)
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return fmt.Errorf("Failed to read finding aid index, %w", err)
+	}
+
+	return nil
+}
+
+// WriteIndex writes the entries in fa to wr as a compact finding aid index.
+
+func (fa *FindingAid) WriteIndex(wr io.Writer) error {
+
+	lookup := make(map[string]map[int][]string)
+
+	for id, e := range fa.entries {
+
+		lines, ok := lookup[e.Path]
+
+		if !ok {
+			lines = make(map[int][]string)
+			lookup[e.Path] = lines
+		}
+
+		lines[e.LineNumber] = append(lines[e.LineNumber], id)
+	}
+
+	paths := make([]string, 0)
+
+	for path := range lookup {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	buf := bufio.NewWriter(wr)
+
+	_, err := fmt.Fprintln(buf, INDEX_HEADER)
+
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+
+		_, err := fmt.Fprintf(buf, "@%s\n", path)
+
+		if err != nil {
+			return err
+		}
+
+		lines := lookup[path]
+		linenos := make([]int, 0)
+
+		for lineno := range lines {
+			linenos = append(linenos, lineno)
+		}
+
+		sort.Ints(linenos)
+
+		for _, lineno := range linenos {
+
+			ids := lines[lineno]
+			sort.Strings(ids)
+
+			_, err := fmt.Fprintf(buf, "%d\t%s\n", lineno, strings.Join(ids, "\t"))
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return buf.Flush()
+}
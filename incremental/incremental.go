@@ -0,0 +1,407 @@
+// package incremental provides methods for recording the state (MD5 hash, modification time and size) of the
+// files processed by a walk so that subsequent walks only need to process the files that have changed.
+package incremental
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileState is the state of a single file in a bucket.
+
+type FileState struct {
+	Path string
+	// The hex-encoded MD5 hash of the file, if known.
+	MD5     string
+	ModTime time.Time
+	Size    int64
+}
+
+// NewFileState returns a new FileState instance for path derived from the properties of obj.
+
+func NewFileState(path string, obj *blob.ListObject) *FileState {
+
+	f := &FileState{
+		Path:    path,
+		ModTime: obj.ModTime,
+		Size:    obj.Size,
+	}
+
+	if len(obj.MD5) > 0 {
+		f.MD5 = hex.EncodeToString(obj.MD5)
+	}
+
+	return f
+}
+
+// Equals returns a boolean value indicating whether f and other describe the same version of a file. If both
+// MD5 hashes are known they are compared, otherwise modification times are compared. Sizes are always compared.
+
+func (f *FileState) Equals(other *FileState) bool {
+
+	if f.Size != other.Size {
+		return false
+	}
+
+	if f.MD5 != "" && other.MD5 != "" {
+		return f.MD5 == other.MD5
+	}
+
+	return f.ModTime.Equal(other.ModTime)
+}
+
+// State is the state of all the files processed by a walk.
+
+type State struct {
+	files   map[string]*FileState
+	pending map[string]*FileState
+	mu      *sync.RWMutex
+}
+
+// NewState returns a new, empty, State instance.
+
+func NewState() *State {
+
+	s := &State{
+		files:   make(map[string]*FileState),
+		pending: make(map[string]*FileState),
+		mu:      new(sync.RWMutex),
+	}
+
+	return s
+}
+
+// ReadState returns a new State instance populated from uri. URIs with a `file://` scheme, or no scheme at all,
+// are treated as paths to a local file. All other URIs are treated as a GoCloud bucket URI whose path is the key
+// of the state file in that bucket. If uri does not exist an empty State instance is returned.
+
+func ReadState(ctx context.Context, uri string) (*State, error) {
+
+	s := NewState()
+
+	r, err := openState(ctx, uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		return s, nil
+	}
+
+	defer r.Close()
+
+	err = s.Read(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read state, %w", err)
+	}
+
+	return s, nil
+}
+
+// Read adds the file states encoded (as CSV) in r to s.
+
+func (s *State) Read(r io.Reader) error {
+
+	csv_r := csv.NewReader(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+
+		row, err := csv_r.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(row) != 4 {
+			return fmt.Errorf("Invalid row, expected 4 columns but got %d", len(row))
+		}
+
+		if row[0] == "path" {
+			continue
+		}
+
+		mod_time, err := time.Parse(time.RFC3339Nano, row[2])
+
+		if err != nil {
+			return fmt.Errorf("Invalid modification time for %s, %w", row[0], err)
+		}
+
+		size, err := strconv.ParseInt(row[3], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("Invalid size for %s, %w", row[0], err)
+		}
+
+		s.files[row[0]] = &FileState{
+			Path:    row[0],
+			MD5:     row[1],
+			ModTime: mod_time,
+			Size:    size,
+		}
+	}
+
+	return nil
+}
+
+// Write writes the file states in s, sorted by path, to wr as CSV.
+
+func (s *State) Write(wr io.Writer) error {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := make([]string, 0)
+
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	csv_wr := csv.NewWriter(wr)
+
+	err := csv_wr.Write([]string{"path", "md5", "mod_time", "size"})
+
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+
+		f := s.files[path]
+
+		row := []string{
+			f.Path,
+			f.MD5,
+			f.ModTime.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(f.Size, 10),
+		}
+
+		err := csv_wr.Write(row)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	csv_wr.Flush()
+	return csv_wr.Error()
+}
+
+// Save writes s to uri. See ReadState for a description of valid URIs.
+
+func (s *State) Save(ctx context.Context, uri string) error {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse state URI, %w", err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+
+		// Write to a temporary file first so that an interrupted save does not clobber the previous state
+
+		path := u.Path
+
+		fh, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+
+		if err != nil {
+			return fmt.Errorf("Failed to create state file, %w", err)
+		}
+
+		err = s.Write(fh)
+
+		if err != nil {
+			fh.Close()
+			os.Remove(fh.Name())
+			return fmt.Errorf("Failed to write state, %w", err)
+		}
+
+		err = fh.Close()
+
+		if err != nil {
+			os.Remove(fh.Name())
+			return fmt.Errorf("Failed to close state file, %w", err)
+		}
+
+		return os.Rename(fh.Name(), path)
+
+	default:
+
+		bucket, key, err := openStateBucket(ctx, u)
+
+		if err != nil {
+			return err
+		}
+
+		defer bucket.Close()
+
+		wr, err := bucket.NewWriter(ctx, key, nil)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create state writer, %w", err)
+		}
+
+		err = s.Write(wr)
+
+		if err != nil {
+			wr.Close()
+			return fmt.Errorf("Failed to write state, %w", err)
+		}
+
+		return wr.Close()
+	}
+}
+
+// Observe returns a boolean value indicating whether f is different from the state recorded for f.Path. If it
+// is then f is kept, pending a call to MarkComplete, as the new state for f.Path.
+
+func (s *State) Observe(f *FileState) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.files[f.Path]
+
+	if ok && existing.Equals(f) {
+		return false
+	}
+
+	s.pending[f.Path] = f
+	return true
+}
+
+// MarkComplete records the state, passed to Observe, for path as the state for path.
+
+func (s *State) MarkComplete(path string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.pending[path]
+
+	if !ok {
+		return
+	}
+
+	s.files[path] = f
+	delete(s.pending, path)
+}
+
+// Get returns the state recorded for path.
+
+func (s *State) Get(path string) (*FileState, bool) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.files[path]
+	return f, ok
+}
+
+// Count returns the number of files whose state has been recorded.
+
+func (s *State) Count() int {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.files)
+}
+
+// openState returns an io.ReadCloser for uri or nil if uri does not exist.
+
+func openState(ctx context.Context, uri string) (io.ReadCloser, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse state URI, %w", err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+
+		fh, err := os.Open(u.Path)
+
+		if err != nil {
+
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("Failed to open state file, %w", err)
+		}
+
+		return fh, nil
+
+	default:
+
+		bucket, key, err := openStateBucket(ctx, u)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer bucket.Close()
+
+		body, err := bucket.ReadAll(ctx, key)
+
+		if err != nil {
+
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("Failed to read state, %w", err)
+		}
+
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+func openStateBucket(ctx context.Context, u *url.URL) (*blob.Bucket, string, error) {
+
+	key := strings.TrimLeft(u.Path, "/")
+
+	if key == "" {
+		return nil, "", fmt.Errorf("State URI is missing a key")
+	}
+
+	bucket_u := *u
+	bucket_u.Path = ""
+
+	bucket, err := blob.OpenBucket(ctx, bucket_u.String())
+
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to open state bucket, %w", err)
+	}
+
+	return bucket, key, nil
+}
@@ -0,0 +1,422 @@
+// package lookup provides methods for fetching individual OpenAccess records, by identifier, using finding aids.
+package lookup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/codec"
+	"github.com/aaronland/go-smithsonian-openaccess/findingaid"
+	"github.com/aaronland/go-smithsonian-openaccess/objecturi"
+	"github.com/aaronland/go-smithsonian-openaccess/walk"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DEFAULT_WORKERS is the default maximum number of metadata files that are read concurrently.
+
+const DEFAULT_WORKERS int = 10
+
+// Result is the outcome of looking up a single identifier.
+
+type Result struct {
+	// The identifier that was looked up.
+	Id string
+	// The finding aid entry for Id, if present.
+	Entry *findingaid.Entry
+	// The record for Id, if it was found.
+	Record *walk.Record
+	// Any error that prevented the record for Id from being found.
+	Err error
+}
+
+// Lookup fetches OpenAccess records from a bucket using the paths and line numbers recorded in a finding aid.
+
+type Lookup struct {
+	// The maximum number of metadata files to read concurrently.
+	Workers     int
+	bucket      *blob.Bucket
+	finding_aid *findingaid.FindingAid
+}
+
+// NewLookup returns a new Lookup instance that fetches records from bucket using the finding aid fa.
+
+func NewLookup(bucket *blob.Bucket, fa *findingaid.FindingAid) *Lookup {
+
+	l := &Lookup{
+		Workers:     DEFAULT_WORKERS,
+		bucket:      bucket,
+		finding_aid: fa,
+	}
+
+	return l
+}
+
+// Get returns the record for id. Identifiers may be any of the identifiers recorded in the finding aid or an
+// `si://` object URI.
+
+func (l *Lookup) Get(ctx context.Context, id string) (*walk.Record, error) {
+
+	results, err := l.GetMany(ctx, []string{id})
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := results[0]
+
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	return r.Record, nil
+}
+
+// GetMany returns a Result for each identifier in ids, in the same order as ids. Identifiers that resolve to the
+// same metadata file are fetched by reading that file once, from the beginning up to the last line that is needed.
+// If the path recorded in the finding aid does not exist in the bucket then the same path with (or without) the
+// extension of each registered codec (for example ".bz2") is tried, so that a finding aid produced from one bucket can
+// be used with a compressed clone of it. Errors for individual identifiers are recorded in each Result; the error
+// returned by GetMany is only non-nil if ctx is cancelled.
+
+func (l *Lookup) GetMany(ctx context.Context, ids []string) ([]*Result, error) {
+
+	results := make([]*Result, len(ids))
+	by_path := make(map[string][]*Result)
+
+	for i, id := range ids {
+
+		r := &Result{
+			Id: id,
+		}
+
+		results[i] = r
+
+		e, err := l.resolve(id)
+
+		if err != nil {
+			r.Err = err
+			continue
+		}
+
+		r.Entry = e
+		by_path[e.Path] = append(by_path[e.Path], r)
+	}
+
+	throttle := make(chan bool, l.workers())
+	wg := new(sync.WaitGroup)
+
+	for path, path_results := range by_path {
+
+		throttle <- true
+		wg.Add(1)
+
+		go func(path string, path_results []*Result) {
+
+			defer func() {
+				<-throttle
+				wg.Done()
+			}()
+
+			l.fetch(ctx, path, path_results)
+
+		}(path, path_results)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return results, nil
+}
+
+func (l *Lookup) workers() int {
+
+	if l.Workers < 1 {
+		return 1
+	}
+
+	return l.Workers
+}
+
+// resolve returns the finding aid entry for id, which may be an `si://` object URI.
+
+func (l *Lookup) resolve(id string) (*findingaid.Entry, error) {
+
+	if strings.HasPrefix(id, objecturi.SCHEME+"://") {
+		return objecturi.Resolve(l.finding_aid, id)
+	}
+
+	return l.finding_aid.Get(id)
+}
+
+// fetch reads the lines for results, all of which reference path, and assigns the decoded record (or an error) to each one.
+
+func (l *Lookup) fetch(ctx context.Context, path string, results []*Result) {
+
+	set_error := func(err error) {
+
+		for _, r := range results {
+			r.Err = err
+		}
+	}
+
+	linenos := make([]int, 0)
+	seen := make(map[int]bool)
+
+	for _, r := range results {
+
+		lineno := r.Entry.LineNumber
+
+		if lineno < 1 {
+			r.Err = fmt.Errorf("Invalid line number %d for '%s'", lineno, r.Id)
+			continue
+		}
+
+		if !seen[lineno] {
+			linenos = append(linenos, lineno)
+			seen[lineno] = true
+		}
+	}
+
+	if len(linenos) == 0 {
+		return
+	}
+
+	sort.Ints(linenos)
+
+	fh, key, err := l.open(ctx, path)
+
+	if err != nil {
+		set_error(err)
+		return
+	}
+
+	defer fh.Close()
+
+	var reader io.Reader = fh
+
+	c, ok := codec.CodecForPath(key)
+
+	if ok {
+
+		dr, err := c.NewReader(fh)
+
+		if err != nil {
+			set_error(fmt.Errorf("Failed to decompress %s, %w", key, err))
+			return
+		}
+
+		defer dr.Close()
+
+		reader = dr
+	}
+
+	lines, err := readLines(ctx, bufio.NewReader(reader), linenos)
+
+	if err != nil {
+		set_error(fmt.Errorf("Failed to read %s, %w", key, err))
+		return
+	}
+
+	for _, r := range results {
+
+		if r.Err != nil {
+			continue
+		}
+
+		body, ok := lines[r.Entry.LineNumber]
+
+		if !ok {
+			r.Err = fmt.Errorf("%s has no line %d, the finding aid may be out of date", key, r.Entry.LineNumber)
+			continue
+		}
+
+		rec, err := newRecord(key, r.Entry.LineNumber, body)
+
+		if err != nil {
+			r.Err = fmt.Errorf("Failed to decode %s line %d, %w", key, r.Entry.LineNumber, err)
+			continue
+		}
+
+		if !hasIdentifier(rec.OpenAccessRecord, r.Id) {
+			r.Err = fmt.Errorf("Record at %s line %d does not match '%s', the finding aid may be out of date", key, r.Entry.LineNumber, r.Id)
+			continue
+		}
+
+		r.Record = rec
+	}
+}
+
+// open returns a reader for path, or for path with (or without) the extension of a registered codec, and the key that was opened.
+
+func (l *Lookup) open(ctx context.Context, path string) (io.ReadCloser, string, error) {
+
+	candidates := []string{path}
+	uncompressed := codec.TrimExtension(path)
+
+	if uncompressed != path {
+		candidates = append(candidates, uncompressed)
+	}
+
+	for _, ext := range codec.Extensions() {
+
+		key := fmt.Sprintf("%s%s", uncompressed, ext)
+
+		if key != path {
+			candidates = append(candidates, key)
+		}
+	}
+
+	for _, key := range candidates {
+
+		fh, err := l.bucket.NewReader(ctx, key, nil)
+
+		if err != nil {
+
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				continue
+			}
+
+			return nil, "", fmt.Errorf("Failed to open %s, %w", key, err)
+		}
+
+		return fh, key, nil
+	}
+
+	return nil, "", fmt.Errorf("Failed to find %s in bucket", path)
+}
+
+// readLines reads r up to the last of linenos, which must be sorted, and returns the (trimmed) body of each of those lines.
+
+func readLines(ctx context.Context, r *bufio.Reader, linenos []int) (map[int][]byte, error) {
+
+	lines := make(map[int][]byte)
+	idx := 0
+
+	for lineno := 1; idx < len(linenos); lineno++ {
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+			// pass
+		}
+
+		if lineno != linenos[idx] {
+
+			err := skipLine(r)
+
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		body, err := r.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		body = bytes.TrimSpace(body)
+
+		if len(body) > 0 {
+			lines[lineno] = body
+		}
+
+		idx += 1
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return lines, nil
+}
+
+// skipLine advances r past the next newline without holding the line in memory. It returns io.EOF if there are no more lines.
+
+func skipLine(r *bufio.Reader) error {
+
+	for i := 0; ; i++ {
+
+		b, err := r.ReadSlice('\n')
+
+		switch err {
+		case nil:
+			return nil
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+
+			if i == 0 && len(b) == 0 {
+				return io.EOF
+			}
+
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+func newRecord(path string, lineno int, body []byte) (*walk.Record, error) {
+
+	var object *openaccess.OpenAccessRecord
+
+	err := json.Unmarshal(body, &object)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &walk.Record{
+		Path:             path,
+		LineNumber:       lineno,
+		Body:             body,
+		OpenAccessRecord: object,
+	}
+
+	return rec, nil
+}
+
+// hasIdentifier returns a boolean value indicating whether id is one of the identifiers that the `findingaid` tool
+// (or the objecturi package) may have recorded for object.
+
+func hasIdentifier(object *openaccess.OpenAccessRecord, id string) bool {
+
+	if strings.HasPrefix(id, objecturi.SCHEME+"://") {
+
+		o, err := objecturi.Parse(id)
+
+		if err != nil {
+			return false
+		}
+
+		id = o.Id
+	}
+
+	nonrepeating := object.Content.DescriptiveNonRepeating
+
+	switch id {
+	case object.Id, nonrepeating.GUID, nonrepeating.RecordId, nonrepeating.RecordLink:
+		return true
+	default:
+		return id == fmt.Sprintf("edanmdm-%s", nonrepeating.RecordId)
+	}
+}
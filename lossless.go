@@ -0,0 +1,277 @@
+package openaccess
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var record_properties map[string]bool
+
+func init() {
+
+	record_properties = make(map[string]bool)
+
+	t := reflect.TypeOf(OpenAccessRecord{})
+
+	for i := 0; i < t.NumField(); i++ {
+
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		record_properties[name] = true
+	}
+}
+
+func isRecordProperty(k string) bool {
+	_, ok := record_properties[k]
+	return ok
+}
+
+func isZeroJSON(v json.RawMessage) bool {
+
+	switch string(bytes.TrimSpace(v)) {
+	case "null", "0", "false", `""`, "{}", "[]":
+		return true
+	default:
+		return false
+	}
+}
+
+// mergeContent encodes content and then merges in any properties from raw (the content as it was originally
+// decoded) that content does not declare. Values in content always take precedence over values in raw and
+// properties that content declares, but which have been assigned a zero value, are removed.
+
+func mergeContent(raw json.RawMessage, content interface{}) (json.RawMessage, error) {
+
+	typed_body, err := json.Marshal(content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return typed_body, nil
+	}
+
+	raw_value, err := decodeJSON(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	typed_value, err := decodeJSON(typed_body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeJSON(raw_value, typed_value, reflect.TypeOf(content))
+	return json.Marshal(merged)
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	err := dec.Decode(&v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// mergeJSON merges raw, a decoded JSON value, in to typed, the decoded JSON encoding of a value of type t. For structs
+// the properties of raw that t does not declare are retained. Properties that t declares but which are absent from typed,
+// because they have been omitted as empty, are only retained if their value in raw is also empty. Otherwise they are
+// assumed to have been cleared and are removed.
+
+func mergeJSON(raw interface{}, typed interface{}, t reflect.Type) interface{} {
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return typed
+	}
+
+	switch typed_v := typed.(type) {
+	case map[string]interface{}:
+
+		raw_v, ok := raw.(map[string]interface{})
+
+		if !ok {
+			return typed
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			return mergeStruct(raw_v, typed_v, jsonFields(t))
+		case reflect.Map:
+
+			// All the keys of a map are declared so any key that is absent from typed has been removed
+
+			merged := make(map[string]interface{})
+
+			for k, v := range typed_v {
+				merged[k] = mergeJSON(raw_v[k], v, t.Elem())
+			}
+
+			return merged
+
+		default:
+			return typed
+		}
+
+	case []interface{}:
+
+		raw_v, ok := raw.([]interface{})
+
+		if !ok || len(raw_v) != len(typed_v) {
+			return typed
+		}
+
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return typed
+		}
+
+		merged := make([]interface{}, len(typed_v))
+
+		for i, v := range typed_v {
+			merged[i] = mergeJSON(raw_v[i], v, t.Elem())
+		}
+
+		return merged
+
+	default:
+		return typed
+	}
+}
+
+// mergeStruct merges raw in to typed, the JSON encoding of a struct whose properties (and their types) are fields.
+
+func mergeStruct(raw map[string]interface{}, typed map[string]interface{}, fields map[string]reflect.Type) map[string]interface{} {
+
+	merged := make(map[string]interface{})
+
+	for k, v := range raw {
+
+		_, declared := fields[k]
+
+		if !declared {
+			merged[k] = v
+			continue
+		}
+
+		_, present := typed[k]
+
+		if !present && isZeroValue(v) {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range typed {
+
+		raw_v, exists := raw[k]
+
+		if !exists {
+
+			// Structs with the `omitempty` tag are still encoded as {} so
+			// don't add properties that weren't there to begin with
+
+			if isZeroValue(v) {
+				continue
+			}
+
+			merged[k] = v
+			continue
+		}
+
+		m := mergeJSON(raw_v, v, fields[k])
+
+		// Likewise a struct that has been assigned its zero value is still encoded as {}
+
+		if isZeroValue(m) && isZeroValue(v) && !isZeroValue(raw_v) {
+			continue
+		}
+
+		merged[k] = m
+	}
+
+	return merged
+}
+
+var json_fields = new(sync.Map)
+
+// jsonFields returns the JSON property names, and their types, declared by the struct t.
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+
+	v, ok := json_fields.Load(t)
+
+	if ok {
+		return v.(map[string]reflect.Type)
+	}
+
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[name] = f.Type
+	}
+
+	json_fields.Store(t, fields)
+	return fields
+}
+
+// isZeroValue returns a boolean value indicating whether v, a decoded JSON value, is empty.
+
+func isZeroValue(v interface{}) bool {
+
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
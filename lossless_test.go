@@ -0,0 +1,183 @@
+package openaccess
+
+import (
+	"encoding/json"
+	"github.com/aaronland/go-smithsonian-openaccess/edan"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+const LOSSLESS_FIXTURE string = "fixtures/nasm/edanmdm-nasm_A19710896000.json"
+
+func readLosslessFixture(t *testing.T) []byte {
+
+	body, err := ioutil.ReadFile(LOSSLESS_FIXTURE)
+
+	if err != nil {
+		t.Fatalf("Failed to read %s, %v", LOSSLESS_FIXTURE, err)
+	}
+
+	// Add properties that are not declared by OpenAccessRecord or edan.IIMObjectRecord
+
+	var doc map[string]interface{}
+
+	err = json.Unmarshal(body, &doc)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal %s, %v", LOSSLESS_FIXTURE, err)
+	}
+
+	doc["example:unknown"] = map[string]interface{}{"a": "b"}
+
+	content := doc["content"].(map[string]interface{})
+	content["example:unknown"] = []interface{}{"c"}
+
+	freetext := content["freetext"].(map[string]interface{})
+	freetext["example:unknown"] = "d"
+
+	body, err = json.Marshal(doc)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal fixture, %v", err)
+	}
+
+	return body
+}
+
+func equalJSON(t *testing.T, a []byte, b []byte) bool {
+
+	var a_v interface{}
+	var b_v interface{}
+
+	err := json.Unmarshal(a, &a_v)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal, %v", err)
+	}
+
+	err = json.Unmarshal(b, &b_v)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal, %v", err)
+	}
+
+	return reflect.DeepEqual(a_v, b_v)
+}
+
+func TestUnmarshalLosslessRoundTrip(t *testing.T) {
+
+	body := readLosslessFixture(t)
+
+	var rec OpenAccessRecord
+
+	err := UnmarshalLossless(body, &rec)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal record, %v", err)
+	}
+
+	enc, err := json.Marshal(rec)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal record, %v", err)
+	}
+
+	if !equalJSON(t, body, enc) {
+		t.Fatalf("Encoded record does not match source, got %s", string(enc))
+	}
+}
+
+func TestUnmarshalLosslessClearedProperties(t *testing.T) {
+
+	body := readLosslessFixture(t)
+
+	var rec OpenAccessRecord
+
+	err := UnmarshalLossless(body, &rec)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal record, %v", err)
+	}
+
+	if len(rec.Content.FreeText.Notes) == 0 {
+		t.Fatalf("Expected %s to have notes", LOSSLESS_FIXTURE)
+	}
+
+	rec.Content.FreeText.Notes = nil
+	rec.Content.DescriptiveNonRepeating.MetadataUsage = edan.IIMUsage{}
+
+	enc, err := json.Marshal(rec)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal record, %v", err)
+	}
+
+	var doc struct {
+		Content struct {
+			DescriptiveNonRepeating map[string]json.RawMessage `json:"descriptiveNonRepeating"`
+			FreeText                map[string]json.RawMessage `json:"freetext"`
+		} `json:"content"`
+	}
+
+	err = json.Unmarshal(enc, &doc)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal encoded record, %v", err)
+	}
+
+	_, ok := doc.Content.FreeText["notes"]
+
+	if ok {
+		t.Fatalf("Expected cleared notes to be removed, got %s", string(enc))
+	}
+
+	_, ok = doc.Content.FreeText["example:unknown"]
+
+	if !ok {
+		t.Fatalf("Expected unknown freetext property to be retained, got %s", string(enc))
+	}
+
+	_, ok = doc.Content.DescriptiveNonRepeating["metadata_usage"]
+
+	if ok {
+		t.Fatalf("Expected cleared metadata_usage to be removed, got %s", string(enc))
+	}
+}
+
+func TestUnmarshalJSONDiscardsUnknownProperties(t *testing.T) {
+
+	body := readLosslessFixture(t)
+
+	var rec OpenAccessRecord
+
+	err := json.Unmarshal(body, &rec)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal record, %v", err)
+	}
+
+	if rec.UnknownProperties != nil {
+		t.Fatalf("Expected unknown properties to be discarded")
+	}
+
+	enc, err := json.Marshal(rec)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal record, %v", err)
+	}
+
+	var doc map[string]interface{}
+
+	err = json.Unmarshal(enc, &doc)
+
+	if err != nil {
+		t.Fatalf("Failed to unmarshal encoded record, %v", err)
+	}
+
+	_, ok := doc["example:unknown"]
+
+	if ok {
+		t.Fatalf("Expected unknown property to be discarded, got %s", string(enc))
+	}
+}
@@ -0,0 +1,229 @@
+package openaccess
+
+import (
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/edan"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const MEDIA_HIGH_RESOLUTION_JPEG string = "high_resolution_jpeg"
+
+const MEDIA_HIGH_RESOLUTION_TIFF string = "high_resolution_tiff"
+
+const MEDIA_SCREEN_IMAGE string = "screen_image"
+
+const MEDIA_THUMBNAIL_IMAGE string = "thumbnail_image"
+
+const MEDIA_AUDIO string = "audio"
+
+const MEDIA_VIDEO string = "video"
+
+const MEDIA_3D string = "3d"
+
+const MEDIA_OTHER string = "other"
+
+// ACCESS_CC0 is the `usage.access` value for media that has been released under a Creative Commons Zero license.
+
+const ACCESS_CC0 string = "CC0"
+
+var re_resource_dimensions *regexp.Regexp
+
+func init() {
+	re_resource_dimensions = regexp.MustCompile(`\((\d+)\s*x\s*(\d+)\)`)
+}
+
+// MediaResource is a single, typed, rendition of a media item.
+
+type MediaResource struct {
+	// One of the MEDIA_ constants.
+	Class string `json:"class"`
+	Label string `json:"label"`
+	URL   string `json:"url"`
+	// The width in pixels, if it can be derived from the resource's label, or 0.
+	Width int `json:"width,omitempty"`
+	// The height in pixels, if it can be derived from the resource's label, or 0.
+	Height int `json:"height,omitempty"`
+}
+
+// HasDimensions returns a boolean value indicating whether the pixel dimensions of r are known.
+
+func (r *MediaResource) HasDimensions() bool {
+	return r.Width > 0 && r.Height > 0
+}
+
+// MediaItem is a single media object, and all its renditions, associated with an OpenAccess record.
+
+type MediaItem struct {
+	IDSId     string           `json:"ids_id,omitempty"`
+	GUID      string           `json:"guid,omitempty"`
+	Type      string           `json:"type"`
+	Access    string           `json:"access"`
+	Thumbnail string           `json:"thumbnail,omitempty"`
+	Resources []*MediaResource `json:"resources"`
+}
+
+// IsCC0 returns a boolean value indicating whether m has been released under a Creative Commons Zero license.
+// Media without an explicit `usage.access` value are considered restricted.
+
+func (m *MediaItem) IsCC0() bool {
+	return strings.EqualFold(m.Access, ACCESS_CC0)
+}
+
+// IsImage returns a boolean value indicating whether m is a (two-dimensional) image.
+
+func (m *MediaItem) IsImage() bool {
+	return strings.EqualFold(m.Type, "Images")
+}
+
+// ResourcesWithClass returns the resources for m whose class is one of the MEDIA_ constants.
+
+func (m *MediaItem) ResourcesWithClass(class string) []*MediaResource {
+
+	resources := make([]*MediaResource, 0)
+
+	for _, r := range m.Resources {
+
+		if r.Class == class {
+			resources = append(resources, r)
+		}
+	}
+
+	return resources
+}
+
+// Media returns the typed media items associated with rec.
+
+func (rec *OpenAccessRecord) Media() []*MediaItem {
+
+	online_media := rec.Content.DescriptiveNonRepeating.OnlineMedia
+	items := make([]*MediaItem, 0)
+
+	for _, m := range online_media.Media {
+
+		item := &MediaItem{
+			IDSId:     m.IDSId,
+			GUID:      m.GUID,
+			Type:      m.Type,
+			Access:    m.Usage.Access,
+			Thumbnail: m.Thumbnail,
+			Resources: make([]*MediaResource, 0),
+		}
+
+		for _, r := range m.Resources {
+			item.Resources = append(item.Resources, newMediaResource(m, r))
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// BestImage returns the largest image rendition, whose width and height are both less than or equal to
+// max_dimension, for rec. If cc0_only is true then only media that have been released under a Creative
+// Commons Zero license are considered. If no rendition with known dimensions is small enough then the
+// first screen image (whose dimensions are unknown) is returned. A max_dimension of 0 means there is no limit.
+
+func (rec *OpenAccessRecord) BestImage(max_dimension int, cc0_only bool) (*MediaItem, *MediaResource, error) {
+
+	var best_item *MediaItem
+	var best_resource *MediaResource
+
+	var fallback_item *MediaItem
+	var fallback_resource *MediaResource
+
+	for _, m := range rec.Media() {
+
+		if !m.IsImage() {
+			continue
+		}
+
+		if cc0_only && !m.IsCC0() {
+			continue
+		}
+
+		for _, r := range m.Resources {
+
+			switch r.Class {
+			case MEDIA_HIGH_RESOLUTION_JPEG, MEDIA_SCREEN_IMAGE, MEDIA_THUMBNAIL_IMAGE:
+				// pass
+			default:
+				continue
+			}
+
+			if !r.HasDimensions() {
+
+				if r.Class == MEDIA_SCREEN_IMAGE && fallback_resource == nil {
+					fallback_item = m
+					fallback_resource = r
+				}
+
+				continue
+			}
+
+			if max_dimension > 0 && (r.Width > max_dimension || r.Height > max_dimension) {
+				continue
+			}
+
+			if best_resource == nil || r.Width*r.Height > best_resource.Width*best_resource.Height {
+				best_item = m
+				best_resource = r
+			}
+		}
+	}
+
+	if best_resource != nil {
+		return best_item, best_resource, nil
+	}
+
+	if fallback_resource != nil {
+		return fallback_item, fallback_resource, nil
+	}
+
+	return nil, nil, fmt.Errorf("Record %s has no suitable images", rec.Id)
+}
+
+func newMediaResource(m edan.IIMMedia, r edan.IIMMediaResource) *MediaResource {
+
+	mr := &MediaResource{
+		Class: classifyMediaResource(m, r),
+		Label: r.Label,
+		URL:   r.URL,
+	}
+
+	match := re_resource_dimensions.FindStringSubmatch(r.Label)
+
+	if match != nil {
+		mr.Width, _ = strconv.Atoi(match[1])
+		mr.Height, _ = strconv.Atoi(match[2])
+	}
+
+	return mr
+}
+
+func classifyMediaResource(m edan.IIMMedia, r edan.IIMMediaResource) string {
+
+	label := strings.ToLower(r.Label)
+	media_type := strings.ToLower(m.Type)
+
+	switch {
+	case strings.HasPrefix(label, "high-resolution jpeg"):
+		return MEDIA_HIGH_RESOLUTION_JPEG
+	case strings.Contains(label, "tiff"):
+		return MEDIA_HIGH_RESOLUTION_TIFF
+	case label == strings.ToLower(SCREEN_IMAGE):
+		return MEDIA_SCREEN_IMAGE
+	case strings.HasPrefix(label, "thumbnail"):
+		return MEDIA_THUMBNAIL_IMAGE
+	case strings.Contains(media_type, "3d"):
+		return MEDIA_3D
+	case strings.Contains(media_type, "video"):
+		return MEDIA_VIDEO
+	case strings.Contains(media_type, "audio"), strings.Contains(media_type, "sound"):
+		return MEDIA_AUDIO
+	default:
+		return MEDIA_OTHER
+	}
+}
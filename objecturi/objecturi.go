@@ -0,0 +1,204 @@
+// package objecturi provides methods for formatting and parsing reversible `si://` object URIs.
+package objecturi
+
+import (
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess/findingaid"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const SCHEME string = "si"
+
+// Object URIs take one of two forms:
+//
+//	si://{UNIT}/o/{OBJECTID}
+//	si://{UNIT}/id/{ID}
+//
+// The first form is used for OpenAccess `id` values that start with the `edanmdm-{UNIT}_` prefix. {OBJECTID} is
+// the remainder of the `id` value. The second form is used for all other OpenAccess `id` values and {ID} is the
+// complete `id` value. In both cases the value is path-escaped so that the original `id` can always be recovered.
+
+const OBJECT_PATH string = "o"
+
+const ID_PATH string = "id"
+
+// ObjectURI is a parsed `si://` object URI.
+
+type ObjectURI struct {
+	// The (lower-case) unit code, for example "nmaahc".
+	Unit string
+	// The OpenAccess `id` of the object, for example "edanmdm-nmaahc_2017.30.9".
+	Id string
+}
+
+// String returns the `si://` URI for o.
+
+func (o *ObjectURI) String() string {
+	return Format(o.Unit, o.Id)
+}
+
+// Format returns the `si://` URI for an OpenAccess `id` published by unit. For example the `id` value
+// "edanmdm-nmaahc_2017.30.9" published by "NMAAHC" becomes "si://nmaahc/o/2017.30.9".
+
+func Format(unit string, id string) string {
+
+	unit = strings.ToLower(unit)
+	prefix := objectIdPrefix(unit)
+
+	// An `id` that is only the prefix would produce an empty, unparseable, object ID
+
+	if strings.HasPrefix(id, prefix) && len(id) > len(prefix) {
+		objectid := strings.TrimPrefix(id, prefix)
+		return fmt.Sprintf("%s://%s/%s/%s", SCHEME, unit, OBJECT_PATH, url.PathEscape(objectid))
+	}
+
+	return fmt.Sprintf("%s://%s/%s/%s", SCHEME, unit, ID_PATH, url.PathEscape(id))
+}
+
+// Parse parses an `si://` URI produced by Format. Units are case-insensitive so "si://NMAH/o/1234" and
+// "si://nmah/o/1234" both refer to the same object.
+
+func Parse(uri string) (*ObjectURI, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Scheme != SCHEME {
+		return nil, fmt.Errorf("Invalid scheme '%s'", u.Scheme)
+	}
+
+	// Units are case-insensitive but object ID prefixes are always lower-case
+
+	unit := strings.ToLower(u.Host)
+
+	if unit == "" {
+		return nil, fmt.Errorf("URI is missing unit")
+	}
+
+	parts := strings.SplitN(strings.TrimLeft(u.EscapedPath(), "/"), "/", 2)
+
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("Invalid path '%s'", u.Path)
+	}
+
+	value, err := url.PathUnescape(parts[1])
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unescape '%s', %w", parts[1], err)
+	}
+
+	o := &ObjectURI{
+		Unit: unit,
+	}
+
+	switch parts[0] {
+	case OBJECT_PATH:
+		o.Id = objectIdPrefix(unit) + value
+	case ID_PATH:
+		o.Id = value
+	default:
+		return nil, fmt.Errorf("Invalid path '%s'", u.Path)
+	}
+
+	return o, nil
+}
+
+// LegacyFormat returns the (irreversible) object URI produced by earlier versions of this package, where the
+// `edanmdm-{UNIT}_` prefix is removed and all instances of the `.` character are replaced with the `_` character.
+
+func LegacyFormat(unit string, id string) string {
+
+	unit = strings.ToLower(unit)
+
+	objectid := strings.Replace(id, objectIdPrefix(unit), "", 1)
+	objectid = strings.Replace(objectid, ".", "_", -1)
+
+	return fmt.Sprintf("%s://%s/%s/%s", SCHEME, unit, OBJECT_PATH, objectid)
+}
+
+// FormatFunc is a function for deriving an object URI from a unit code and an OpenAccess `id`.
+
+type FormatFunc func(string, string) string
+
+// CollisionError is returned when two different OpenAccess `id` values are formatted as the same object URI.
+
+type CollisionError struct {
+	URI        string
+	Id         string
+	ExistingId string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("Object URI %s for '%s' collides with '%s'", e.URI, e.Id, e.ExistingId)
+}
+
+// CollisionDetector keeps track of the object URIs derived from OpenAccess `id` values and reports when two different
+// values produce the same URI. This is principally useful for auditing URIs produced by LegacyFormat.
+
+type CollisionDetector struct {
+	format FormatFunc
+	seen   map[string]string
+	mu     *sync.Mutex
+}
+
+// NewCollisionDetector returns a new CollisionDetector that derives object URIs using format.
+
+func NewCollisionDetector(format FormatFunc) *CollisionDetector {
+
+	d := &CollisionDetector{
+		format: format,
+		seen:   make(map[string]string),
+		mu:     new(sync.Mutex),
+	}
+
+	return d
+}
+
+// Add derives the object URI for id and returns it. If a different `id` has already produced the same
+// URI a *CollisionError is returned.
+
+func (d *CollisionDetector) Add(unit string, id string) (string, error) {
+
+	uri := d.format(unit, id)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, ok := d.seen[uri]
+
+	if ok && existing != id {
+
+		e := &CollisionError{
+			URI:        uri,
+			Id:         id,
+			ExistingId: existing,
+		}
+
+		return uri, e
+	}
+
+	d.seen[uri] = id
+	return uri, nil
+}
+
+// Resolve returns the finding aid entry (the path and line number of a record in a bucket) for an `si://` URI.
+
+func Resolve(fa *findingaid.FindingAid, uri string) (*findingaid.Entry, error) {
+
+	o, err := Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fa.Get(o.Id)
+}
+
+func objectIdPrefix(unit string) string {
+	return fmt.Sprintf("edanmdm-%s_", unit)
+}
@@ -0,0 +1,101 @@
+package objecturi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatParseRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		unit     string
+		id       string
+		expected string
+	}{
+		{"NMAAHC", "edanmdm-nmaahc_2017.30.9", "si://nmaahc/o/2017.30.9"},
+		{"nasm", "edanmdm-nasm_A19710896000", "si://nasm/o/A19710896000"},
+		{"CHNDM", "edanmdm-chndm_1972-42-130-a_b", "si://chndm/o/1972-42-130-a_b"},
+		{"SIA", "ead_collection:sova-sia-faru7091", "si://sia/id/ead_collection:sova-sia-faru7091"},
+		{"NMAH", "edanmdm-nmnhvz_1234", "si://nmah/id/edanmdm-nmnhvz_1234"},
+		{"NMAH", "edanmdm-nmah_", "si://nmah/id/edanmdm-nmah_"},
+		{"NMAH", "edanmdm-nmah_a/b?c#d%e f", "si://nmah/o/a%2Fb%3Fc%23d%25e%20f"},
+		{"NPG", "edanmdm-npg_NPG.77.1/2", "si://npg/o/NPG.77.1%2F2"},
+		{"FSG", "edanmdm-fsg_F1904.61ä", "si://fsg/o/F1904.61%C3%A4"},
+	}
+
+	for _, test := range tests {
+
+		uri := Format(test.unit, test.id)
+
+		if uri != test.expected {
+			t.Errorf("Expected '%s' for %s %s but got '%s'", test.expected, test.unit, test.id, uri)
+			continue
+		}
+
+		o, err := Parse(uri)
+
+		if err != nil {
+			t.Errorf("Failed to parse '%s', %v", uri, err)
+			continue
+		}
+
+		if o.Unit != strings.ToLower(test.unit) {
+			t.Errorf("Expected unit '%s' for '%s' but got '%s'", strings.ToLower(test.unit), uri, o.Unit)
+		}
+
+		if o.Id != test.id {
+			t.Errorf("Expected id '%s' for '%s' but got '%s'", test.id, uri, o.Id)
+		}
+
+		if o.String() != uri {
+			t.Errorf("Expected '%s' to format as itself but got '%s'", uri, o.String())
+		}
+	}
+}
+
+func TestParseUppercaseUnit(t *testing.T) {
+
+	tests := map[string]string{
+		"si://NMAH/o/1234":           "edanmdm-nmah_1234",
+		"si://Nmah/o/1234":           "edanmdm-nmah_1234",
+		"si://SIA/id/ead_collection": "ead_collection",
+	}
+
+	for uri, id := range tests {
+
+		o, err := Parse(uri)
+
+		if err != nil {
+			t.Errorf("Failed to parse '%s', %v", uri, err)
+			continue
+		}
+
+		if o.Id != id {
+			t.Errorf("Expected '%s' to parse as '%s' but got '%s'", uri, id, o.Id)
+		}
+
+		if o.Unit != strings.ToLower(o.Unit) {
+			t.Errorf("Expected lower-case unit for '%s' but got '%s'", uri, o.Unit)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+
+	tests := []string{
+		"http://nasm/o/A19710896000",
+		"si:///o/A19710896000",
+		"si://nasm/o/",
+		"si://nasm/x/A19710896000",
+		"si://nasm",
+	}
+
+	for _, uri := range tests {
+
+		_, err := Parse(uri)
+
+		if err == nil {
+			t.Errorf("Expected '%s' to fail to parse", uri)
+		}
+	}
+}
@@ -4,59 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/units"
 	"github.com/aaronland/go-wunderkammer/oembed"
-	"github.com/jtacoma/uritemplates"
 	"net/url"
-	"strings"
 )
 
-const OBJECT_URI_TEMPLATE string = "si://{collection}/o/{objectid}"
-
-var object_uri_template *uritemplates.UriTemplate
-
-func init() {
-
-	t, err := uritemplates.Parse(OBJECT_URI_TEMPLATE)
+// OBJECT_URI_TEMPLATE is the general form of the non-standard `object_uri` property. Object URIs are
+// constructed, and parsed, using the objecturi package.
 
-	if err != nil {
-		panic(err)
-	}
-
-	object_uri_template = t
-}
+const OBJECT_URI_TEMPLATE string = "si://{collection}/o/{objectid}"
 
 func OEmbedRecordsFromOpenAccessRecord(rec *openaccess.OpenAccessRecord) ([]*oembed.Photo, error) {
 
-	images, err := rec.ImageURLsWithLabel(openaccess.SCREEN_IMAGE)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if len(images) == 0 {
-
-		online_media, err := rec.OnlineMedia()
-
-		if err != nil {
-			return nil, err
-		}
-
-		if online_media.MediaCount >= 1 {
-
-			for _, m := range online_media.Media {
-
-				if m.Type != "Images" {
-					continue
-				}
-
-				if m.Thumbnail == "" {
-					continue
-				}
-
-				images = append(images, m.Thumbnail)
-			}
-		}
-	}
+	images := imageResources(rec)
 
 	if len(images) == 0 {
 
@@ -81,16 +41,11 @@ func OEmbedRecordsFromOpenAccessRecord(rec *openaccess.OpenAccessRecord) ([]*oem
 	author_name := fmt.Sprintf("Collection of %s", provider_name)
 	provider_url := author_url
 
-	if rec.Content.FreeText.Name != nil {
-		author_name = rec.Content.FreeText.Name[0].Content
-	} else if rec.Content.FreeText.Manufacturer != nil {
-		author_name = rec.Content.FreeText.Manufacturer[0].Content
-	} else {
-		// pass
-	}
+	agents := rec.Agents()
 
-	// object_uri and author_url
-	// ...please write me
+	if len(agents) > 0 {
+		author_name = agents[0].Name
+	}
 
 	// https://nmaahc.si.edu/object/nmaahc_2010.39.8
 	// si://nmaahc/o/2011_155_299ab
@@ -101,41 +56,53 @@ func OEmbedRecordsFromOpenAccessRecord(rec *openaccess.OpenAccessRecord) ([]*oem
 	// http://collection.cooperhewitt.org/view/objects/asitem/id/81405
 	// si://chndm/o/1972-42-130-a_b
 
-	unit := rec.UnitCode
-	unit = strings.ToLower(unit)
-
-	objectid_prefix := fmt.Sprintf("edanmdm-%s_", unit)
+	// See the objecturi package for details on how object URIs are derived
 
-	objectid := rec.Id
-	objectid = strings.Replace(objectid, objectid_prefix, "", 1)
-	objectid = strings.Replace(objectid, ".", "_", -1)
+	unit, err := units.GetUnitForRecord(rec)
 
-	values := make(map[string]interface{})
-	values["collection"] = unit
-	values["objectid"] = objectid
+	if err != nil {
+		unit = units.DefaultUnit(rec.UnitCode)
+	}
 
-	object_uri, err := object_uri_template.Expand(values)
+	object_uri, err := unit.ObjectURI(rec)
 
 	if err != nil {
 		return nil, err
 	}
 
-	u, err := url.Parse(provider_url)
+	if unit.URL != "" {
+		provider_url = unit.URL
+	} else {
+
+		u, err := url.Parse(provider_url)
 
-	if err == nil {
-		u.Path = ""
-		u.RawQuery = ""
-		provider_url = u.String()
+		if err == nil {
+			u.Path = ""
+			u.RawQuery = ""
+			provider_url = u.String()
+		}
 	}
 
-	for _, url := range images {
+	for _, r := range images {
+
+		// Dimensions are only known if they are included in the label of the resource being
+		// emitted, which is never the case for screen images
+		// https://github.com/Smithsonian/OpenAccess/issues/2
+
+		width := -1
+		height := -1
+
+		if r.HasDimensions() {
+			width = r.Width
+			height = r.Height
+		}
 
 		o := &oembed.Photo{
 			Version:      "1.0",
 			Type:         "photo",
-			Height:       -1, // https://github.com/Smithsonian/OpenAccess/issues/2
-			Width:        -1, // see above
-			URL:          url,
+			Height:       height,
+			Width:        width,
+			URL:          r.URL,
 			Title:        title,
 			AuthorName:   author_name,
 			AuthorURL:    author_url,
@@ -149,3 +116,45 @@ func OEmbedRecordsFromOpenAccessRecord(rec *openaccess.OpenAccessRecord) ([]*oem
 
 	return records, nil
 }
+
+// imageResources returns one resource for each image associated with rec preferring, in order: screen images,
+// high-resolution JPEG images and thumbnail images. Screen images are preferred even though their labels never
+// include their dimensions.
+
+func imageResources(rec *openaccess.OpenAccessRecord) []*openaccess.MediaResource {
+
+	images := make([]*openaccess.MediaResource, 0)
+
+	for _, m := range rec.Media() {
+
+		if !m.IsImage() {
+			continue
+		}
+
+		screen := m.ResourcesWithClass(openaccess.MEDIA_SCREEN_IMAGE)
+
+		if len(screen) > 0 {
+			images = append(images, screen[0])
+			continue
+		}
+
+		jpeg := m.ResourcesWithClass(openaccess.MEDIA_HIGH_RESOLUTION_JPEG)
+
+		if len(jpeg) > 0 {
+			images = append(images, jpeg[0])
+			continue
+		}
+
+		if m.Thumbnail != "" {
+
+			r := &openaccess.MediaResource{
+				Class: openaccess.MEDIA_THUMBNAIL_IMAGE,
+				URL:   m.Thumbnail,
+			}
+
+			images = append(images, r)
+		}
+	}
+
+	return images
+}
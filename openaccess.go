@@ -1,7 +1,10 @@
 package openaccess
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/aaronland/go-smithsonian-openaccess/edan"
+	"reflect"
 	"strings"
 )
 
@@ -11,7 +14,7 @@ const SCREEN_IMAGE string = "Screen Image"
 
 type OpenAccessRecord struct {
 	Id              string               `json:"id"`
-	Title           string               `json:title"`
+	Title           string               `json:"title"`
 	UnitCode        string               `json:"unitCode"`
 	LinkedId        string               `json:"linkedId"`
 	Type            string               `json:"type"`
@@ -25,6 +28,194 @@ type OpenAccessRecord struct {
 	Version         string               `json:"version"`
 	PublicSearch    bool                 `json:"publicSearch"`
 	Extensions      interface{}          `json:"extensions"`
+	// The raw bytes of the `content` property, as they were decoded.
+	RawContent json.RawMessage `json:"-"`
+	// Top-level properties not declared by OpenAccessRecord, as they were decoded.
+	UnknownProperties map[string]json.RawMessage `json:"-"`
+	decoded_keys      map[string]bool
+	// The error, if any, returned when RawContent was decoded as an edan.IIMObjectRecord.
+	content_err error
+}
+
+type openAccessRecordAlias OpenAccessRecord
+
+// UnmarshalJSON decodes body in to rec. The raw bytes of the `content` property are retained so that ContentRecord
+// can decode it according to the record's `type` property. For backwards compatibility the `content` property is
+// also decoded as an edan.IIMObjectRecord. If the record's type is "edanmdm" then failing to do so is an error.
+// Records of any other type may have content with a different shape so, if it can not be decoded as an
+// edan.IIMObjectRecord, the Content property is left empty and the raw bytes are encoded as-is by MarshalJSON.
+// Properties that OpenAccessRecord does not declare are discarded. Use UnmarshalLossless to retain them.
+
+func (rec *OpenAccessRecord) UnmarshalJSON(body []byte) error {
+	return rec.unmarshal(body, false)
+}
+
+// UnmarshalLossless decodes body in to rec, in the same way as UnmarshalJSON, but also retains any top-level
+// properties that OpenAccessRecord does not declare in UnknownProperties and keeps track of the properties
+// that were present so that MarshalJSON can re-encode the record without losing any of its original data.
+// This is slower than UnmarshalJSON so it should only be used by code that decodes records in order to
+// modify and encode them again.
+
+func UnmarshalLossless(body []byte, rec *OpenAccessRecord) error {
+	return rec.unmarshal(body, true)
+}
+
+func (rec *OpenAccessRecord) unmarshal(body []byte, lossless bool) error {
+
+	aux := struct {
+		*openAccessRecordAlias
+		Content json.RawMessage `json:"content"`
+	}{
+		openAccessRecordAlias: (*openAccessRecordAlias)(rec),
+	}
+
+	err := json.Unmarshal(body, &aux)
+
+	if err != nil {
+		return err
+	}
+
+	rec.RawContent = aux.Content
+	rec.Content = edan.IIMObjectRecord{}
+	rec.content_err = nil
+
+	if len(aux.Content) > 0 {
+
+		err = json.Unmarshal(aux.Content, &rec.Content)
+
+		if err != nil {
+
+			if rec.Type == edan.EDANMDM {
+				return err
+			}
+
+			rec.Content = edan.IIMObjectRecord{}
+			rec.content_err = err
+		}
+	}
+
+	rec.UnknownProperties = nil
+	rec.decoded_keys = nil
+
+	if !lossless {
+		return nil
+	}
+
+	var properties map[string]json.RawMessage
+
+	err = json.Unmarshal(body, &properties)
+
+	if err != nil {
+		return err
+	}
+
+	rec.decoded_keys = make(map[string]bool)
+
+	for k, v := range properties {
+
+		rec.decoded_keys[k] = true
+
+		if isRecordProperty(k) {
+			continue
+		}
+
+		if rec.UnknownProperties == nil {
+			rec.UnknownProperties = make(map[string]json.RawMessage)
+		}
+
+		rec.UnknownProperties[k] = v
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes rec. If rec was decoded using UnmarshalLossless then a record which has been decoded and
+// then encoded again retains all of its original data: properties of the `content` property that edan.IIMObjectRecord
+// does not declare are merged back in from RawContent, UnknownProperties are re-emitted verbatim and declared
+// properties that were absent from the original record are not added unless they have been assigned a value.
+// Declared properties that have been assigned a zero value (for example a slice that has been set to nil) are removed.
+
+func (rec OpenAccessRecord) MarshalJSON() ([]byte, error) {
+
+	content := rec.RawContent
+	lossless := rec.decoded_keys != nil
+
+	// Content that could not be decoded as an edan.IIMObjectRecord is encoded as-is unless it has since been assigned a value
+
+	if rec.content_err == nil || !reflect.ValueOf(rec.Content).IsZero() {
+
+		var body []byte
+		var err error
+
+		if lossless {
+			body, err = mergeContent(rec.RawContent, rec.Content)
+		} else {
+			body, err = json.Marshal(rec.Content)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode content for record %s, %w", rec.Id, err)
+		}
+
+		content = body
+	}
+
+	alias := openAccessRecordAlias(rec)
+
+	aux := struct {
+		*openAccessRecordAlias
+		Content json.RawMessage `json:"content"`
+	}{
+		openAccessRecordAlias: &alias,
+		Content:               content,
+	}
+
+	body, err := json.Marshal(aux)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !lossless && len(rec.UnknownProperties) == 0 {
+		return body, nil
+	}
+
+	var properties map[string]json.RawMessage
+
+	err = json.Unmarshal(body, &properties)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if lossless {
+
+		for k, v := range properties {
+
+			if !rec.decoded_keys[k] && isZeroJSON(v) {
+				delete(properties, k)
+			}
+		}
+	}
+
+	for k, v := range rec.UnknownProperties {
+		properties[k] = v
+	}
+
+	return json.Marshal(properties)
+}
+
+// ContentRecord returns the record's `content` property decoded in to the struct registered for its
+// `type` property (for example *edan.IIMObjectRecord or *edan.EADRecord) or an *edan.GenericRecord if
+// the type is unknown.
+
+func (rec *OpenAccessRecord) ContentRecord() (interface{}, error) {
+
+	if len(rec.RawContent) == 0 {
+		return nil, fmt.Errorf("Record %s has no content", rec.Id)
+	}
+
+	return edan.UnmarshalContent(rec.Type, rec.RawContent)
 }
 
 func (rec *OpenAccessRecord) OnlineMedia() (edan.IIMOnlineMedia, error) {
@@ -65,3 +256,78 @@ func (rec *OpenAccessRecord) CreditLine() string {
 
 	return strings.Join(phrases, " ")
 }
+
+// DateRanges returns the parsed `content.freetext.date` values for rec or, if there are none, the parsed
+// `content.indexedStructured.date` values. Date strings that can not be parsed are skipped.
+
+func (rec *OpenAccessRecord) DateRanges() []*edan.DateRange {
+
+	ranges := make([]*edan.DateRange, 0)
+
+	for _, d := range rec.Content.FreeText.Date {
+
+		r, err := edan.ParseDate(d.Content)
+
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) > 0 {
+		return ranges
+	}
+
+	for _, d := range rec.Content.IndexedStructured.Date {
+
+		r, err := edan.ParseDate(d)
+
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// DateRange returns a single edan.DateRange spanning all the values returned by DateRanges. It returns
+// an error if rec has no parseable dates.
+
+func (rec *OpenAccessRecord) DateRange() (*edan.DateRange, error) {
+
+	ranges := rec.DateRanges()
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("Record %s has no parseable dates", rec.Id)
+	}
+
+	return edan.UnionDateRanges(ranges...)
+}
+
+// Agents returns the parsed `content.freetext.name` and `content.freetext.manufacturer` values for rec,
+// in that order.
+
+func (rec *OpenAccessRecord) Agents() []*edan.Agent {
+
+	agents := make([]*edan.Agent, 0)
+
+	for _, n := range rec.Content.FreeText.Name {
+		agents = append(agents, edan.ParseAgent(n.Content, n.Label))
+	}
+
+	for _, m := range rec.Content.FreeText.Manufacturer {
+
+		label := m.Label
+
+		if label == "" {
+			label = "Manufacturer"
+		}
+
+		agents = append(agents, edan.ParseAgent(m.Content, label))
+	}
+
+	return agents
+}
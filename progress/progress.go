@@ -0,0 +1,241 @@
+// package progress provides methods for tracking and reporting the progress of long-running walks.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Monitor keeps track of the progress of one or more walks. All of its methods are safe to call concurrently
+// and on a nil Monitor, in which case they do nothing.
+
+type Monitor struct {
+	started         time.Time
+	files_listed    int64
+	files_completed int64
+	bytes_listed    int64
+	bytes_read      int64
+	records         int64
+	emitted         int64
+	errors          int64
+	listing         int64
+}
+
+// NewMonitor returns a new Monitor instance whose elapsed time starts now.
+
+func NewMonitor() *Monitor {
+
+	m := &Monitor{
+		started: time.Now(),
+	}
+
+	return m
+}
+
+// StartListing signals that a bucket listing has started. While any listing is in progress the total size of the
+// walk is not yet known so estimates of the time remaining only account for the files listed so far.
+
+func (m *Monitor) StartListing() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.listing, 1)
+}
+
+// EndListing signals that a bucket listing has finished.
+
+func (m *Monitor) EndListing() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.listing, -1)
+}
+
+// FileListed records a file, of size bytes, that will be walked.
+
+func (m *Monitor) FileListed(size int64) {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.files_listed, 1)
+	atomic.AddInt64(&m.bytes_listed, size)
+}
+
+// FileCompleted records that all the records in a file have been processed.
+
+func (m *Monitor) FileCompleted() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.files_completed, 1)
+}
+
+// BytesRead records that n (possibly compressed) bytes have been read from a bucket.
+
+func (m *Monitor) BytesRead(n int64) {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.bytes_read, n)
+}
+
+// RecordRead records that a record has been read.
+
+func (m *Monitor) RecordRead() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.records, 1)
+}
+
+// RecordEmitted records that a record has been emitted, for example written to an output. Records may be read
+// but not emitted because they were filtered out or could not be processed.
+
+func (m *Monitor) RecordEmitted() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.emitted, 1)
+}
+
+// Error records that a record, or file, could not be processed.
+
+func (m *Monitor) Error() {
+
+	if m == nil {
+		return
+	}
+
+	atomic.AddInt64(&m.errors, 1)
+}
+
+// Snapshot returns the current progress for m.
+
+func (m *Monitor) Snapshot() *Snapshot {
+
+	if m == nil {
+		return &Snapshot{}
+	}
+
+	s := &Snapshot{
+		FilesListed:    atomic.LoadInt64(&m.files_listed),
+		FilesCompleted: atomic.LoadInt64(&m.files_completed),
+		BytesListed:    atomic.LoadInt64(&m.bytes_listed),
+		BytesRead:      atomic.LoadInt64(&m.bytes_read),
+		Records:        atomic.LoadInt64(&m.records),
+		RecordsEmitted: atomic.LoadInt64(&m.emitted),
+		Errors:         atomic.LoadInt64(&m.errors),
+		Listing:        atomic.LoadInt64(&m.listing) > 0,
+		Elapsed:        time.Since(m.started),
+	}
+
+	return s
+}
+
+// Report writes a summary of the progress for m to wr every interval until ctx is cancelled.
+
+func Report(ctx context.Context, m *Monitor, wr io.Writer, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Fprintln(wr, m.Snapshot().String())
+		}
+	}
+}
+
+// Snapshot is the progress of a walk at a point in time.
+
+type Snapshot struct {
+	FilesListed    int64
+	FilesCompleted int64
+	BytesListed    int64
+	BytesRead      int64
+	// The number of records read.
+	Records int64
+	// The number of records emitted.
+	RecordsEmitted int64
+	Errors         int64
+	// Listing is true if a bucket listing is still in progress.
+	Listing bool
+	Elapsed time.Duration
+}
+
+// RecordsPerSecond returns the average number of records read per second.
+
+func (s *Snapshot) RecordsPerSecond() float64 {
+
+	seconds := s.Elapsed.Seconds()
+
+	if seconds == 0 {
+		return 0
+	}
+
+	return float64(s.Records) / seconds
+}
+
+// ETA returns the estimated time remaining, derived from the number of bytes read so far relative to the
+// sizes of the files in the bucket listing, and a boolean value indicating whether an estimate is available.
+// If s.Listing is true the estimate only accounts for the files that have been listed so far so it is a lower bound.
+
+func (s *Snapshot) ETA() (time.Duration, bool) {
+
+	if s.BytesRead == 0 || s.BytesListed == 0 {
+		return 0, false
+	}
+
+	remaining := s.BytesListed - s.BytesRead
+
+	if remaining <= 0 {
+		return 0, true
+	}
+
+	eta := time.Duration(float64(s.Elapsed) * float64(remaining) / float64(s.BytesRead))
+	return eta, true
+}
+
+// String returns a one-line, human-readable, summary of s.
+
+func (s *Snapshot) String() string {
+
+	eta := "unknown"
+
+	d, ok := s.ETA()
+
+	switch {
+	case ok && s.Listing:
+		eta = fmt.Sprintf("at least %s (listing)", d.Round(time.Second))
+	case ok:
+		eta = d.Round(time.Second).String()
+	case s.Listing:
+		eta = "unknown (listing)"
+	default:
+		// pass
+	}
+
+	return fmt.Sprintf("files %d/%d, bytes %d/%d, records %d (%.1f/s), emitted %d, errors %d, elapsed %v, eta %s",
+		s.FilesCompleted, s.FilesListed, s.BytesRead, s.BytesListed, s.Records, s.RecordsPerSecond(), s.RecordsEmitted,
+		s.Errors, s.Elapsed.Round(time.Second), eta)
+}
@@ -0,0 +1,78 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PROMETHEUS_NAMESPACE is the prefix for all the metrics written by WritePrometheus.
+
+const PROMETHEUS_NAMESPACE string = "openaccess_walk"
+
+// WritePrometheus writes s to wr using the Prometheus text exposition format.
+
+func (s *Snapshot) WritePrometheus(wr io.Writer) error {
+
+	eta := -1.0
+
+	if d, ok := s.ETA(); ok {
+		eta = d.Seconds()
+	}
+
+	listing := 0
+
+	if s.Listing {
+		listing = 1
+	}
+
+	metrics := []struct {
+		name  string
+		kind  string
+		help  string
+		value interface{}
+	}{
+		{"files_listed_total", "counter", "The number of files listed for walking.", s.FilesListed},
+		{"files_completed_total", "counter", "The number of files that have been completely walked.", s.FilesCompleted},
+		{"bytes_listed_total", "counter", "The total size, in bytes, of the files listed for walking.", s.BytesListed},
+		{"bytes_read_total", "counter", "The number of bytes read.", s.BytesRead},
+		{"records_read_total", "counter", "The number of records read.", s.Records},
+		{"records_emitted_total", "counter", "The number of records emitted.", s.RecordsEmitted},
+		{"errors_total", "counter", "The number of records or files that could not be processed.", s.Errors},
+		{"records_per_second", "gauge", "The average number of records read per second.", s.RecordsPerSecond()},
+		{"elapsed_seconds", "gauge", "The number of seconds since the walk started.", s.Elapsed.Seconds()},
+		{"eta_seconds", "gauge", "The estimated number of seconds remaining, or -1 if unknown. While a listing is in progress this is a lower bound.", eta},
+		{"listing", "gauge", "1 if a bucket listing is in progress, otherwise 0.", listing},
+	}
+
+	for _, m := range metrics {
+
+		name := fmt.Sprintf("%s_%s", PROMETHEUS_NAMESPACE, m.name)
+
+		_, err := fmt.Fprintf(wr, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, m.help, name, m.kind, name, m.value)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler returns an http.Handler that writes the current progress for m using the Prometheus text exposition format.
+
+func Handler(m *Monitor) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		rsp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		err := m.Snapshot().WritePrometheus(rsp)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,234 @@
+package shard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MergeJSONL writes the line-delimited JSON records read from each reader in readers, in order, to wr.
+// Empty lines are skipped. Records are concatenated rather than merged because OpenAccess records (for example the
+// output of the emit tool) do not contain the path and line number they were read from. This means that the output of
+// merging sharded walks will contain the same records as a single, unsharded, walk but not in the same order, even if
+// each shard was walked with the -ordered flag. Use MergeCSV with the output of the findingaid tool if order matters.
+
+func MergeJSONL(ctx context.Context, wr io.Writer, readers ...io.Reader) error {
+
+	for idx, r := range readers {
+
+		reader := bufio.NewReader(r)
+
+		for {
+
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				// pass
+			}
+
+			body, err := reader.ReadBytes('\n')
+
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("Failed to read input %d, %w", idx, err)
+			}
+
+			body = bytes.TrimSpace(body)
+
+			if len(body) > 0 {
+
+				_, w_err := wr.Write(append(body, '\n'))
+
+				if w_err != nil {
+					return fmt.Errorf("Failed to write record, %w", w_err)
+				}
+			}
+
+			if err == io.EOF {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// MergeCSV writes the rows read from each reader in readers to wr. If has_header is true the first row of each
+// reader is treated as a header; all the headers must match and the header is only written once. If the header
+// contains "path" and "line_number" columns (for example the output of the findingaid tool) and each reader is
+// sorted by path and line number (for example when the -ordered flag is used) then the merged output will also be
+// sorted. Otherwise rows are written in the order of readers.
+
+func MergeCSV(ctx context.Context, wr io.Writer, has_header bool, readers ...io.Reader) error {
+
+	csv_wr := csv.NewWriter(wr)
+
+	inputs := make([]*csvInput, 0)
+	var header []string
+
+	for idx, r := range readers {
+
+		in := &csvInput{
+			reader: csv.NewReader(r),
+		}
+
+		if has_header {
+
+			row, err := in.reader.Read()
+
+			if err == io.EOF {
+				continue
+			}
+
+			if err != nil {
+				return fmt.Errorf("Failed to read header for input %d, %w", idx, err)
+			}
+
+			if header == nil {
+				header = row
+			} else if !equalRows(header, row) {
+				return fmt.Errorf("Header for input %d does not match header for first input", idx)
+			}
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	path_idx := -1
+	line_idx := -1
+
+	for i, col := range header {
+
+		switch col {
+		case "path":
+			path_idx = i
+		case "line_number":
+			line_idx = i
+		}
+	}
+
+	if header != nil {
+
+		err := csv_wr.Write(header)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write header, %w", err)
+		}
+	}
+
+	for idx, in := range inputs {
+
+		err := in.next()
+
+		if err != nil {
+			return fmt.Errorf("Failed to read input %d, %w", idx, err)
+		}
+	}
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			// pass
+		}
+
+		var current *csvInput
+
+		for _, in := range inputs {
+
+			if in.row == nil {
+				continue
+			}
+
+			if current == nil {
+				current = in
+				continue
+			}
+
+			if path_idx == -1 || line_idx == -1 {
+				break
+			}
+
+			if lessRow(in.row, current.row, path_idx, line_idx) {
+				current = in
+			}
+		}
+
+		if current == nil {
+			break
+		}
+
+		err := csv_wr.Write(current.row)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write row, %w", err)
+		}
+
+		err = current.next()
+
+		if err != nil {
+			return fmt.Errorf("Failed to read row, %w", err)
+		}
+	}
+
+	csv_wr.Flush()
+	return csv_wr.Error()
+}
+
+type csvInput struct {
+	reader *csv.Reader
+	row    []string
+}
+
+// next reads the next row for in. Once the reader is exhausted in.row is nil.
+
+func (in *csvInput) next() error {
+
+	row, err := in.reader.Read()
+
+	if err == io.EOF {
+		in.row = nil
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	in.row = row
+	return nil
+}
+
+func lessRow(a []string, b []string, path_idx int, line_idx int) bool {
+
+	if a[path_idx] != b[path_idx] {
+		return a[path_idx] < b[path_idx]
+	}
+
+	a_line, _ := strconv.Atoi(a[line_idx])
+	b_line, _ := strconv.Atoi(b[line_idx])
+
+	return a_line < b_line
+}
+
+func equalRows(a []string, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
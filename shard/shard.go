@@ -0,0 +1,83 @@
+// package shard provides methods for deterministically partitioning OpenAccess metadata files across multiple
+// processes and for merging the outputs of those processes.
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+)
+
+// Key returns the sharding key for path. Metadata files are stored as metadata/edan/{UNIT}/{HASH}.txt so
+// the key is "{UNIT}/{HASH}". Compression suffixes and file extensions are removed so that a file and its
+// (compressed) clone are always assigned to the same shard, regardless of which bucket they are read from.
+
+func Key(path string) string {
+
+	fname := filepath.Base(path)
+	unit := filepath.Base(filepath.Dir(path))
+
+	for {
+
+		ext := filepath.Ext(fname)
+
+		if ext == "" {
+			break
+		}
+
+		fname = strings.TrimSuffix(fname, ext)
+	}
+
+	return fmt.Sprintf("%s/%s", unit, fname)
+}
+
+// Index returns the (zero-based) index of the shard, out of count shards, that path belongs to.
+
+func Index(path string, count int) int {
+
+	if count <= 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(Key(path)))
+
+	return int(h.Sum32() % uint32(count))
+}
+
+// Contains returns a boolean value indicating whether path belongs to shard index out of count shards.
+// If count is less than or equal to 1 then every path belongs to the (only) shard.
+
+func Contains(path string, index int, count int) bool {
+
+	if count <= 1 {
+		return true
+	}
+
+	return Index(path, count) == index
+}
+
+// Validate ensures that index is a valid (zero-based) shard index for count shards.
+
+func Validate(index int, count int) error {
+
+	if count < 0 {
+		return fmt.Errorf("Invalid shard count %d", count)
+	}
+
+	if count <= 1 {
+
+		if index != 0 {
+			return fmt.Errorf("Invalid shard index %d for %d shard(s)", index, count)
+		}
+
+		return nil
+	}
+
+	if index < 0 || index >= count {
+		return fmt.Errorf("Invalid shard index %d, must be between 0 and %d", index, count-1)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package openaccess
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Created returns the time at which rec was first published, derived from its `timestamp` property.
+
+func (rec *OpenAccessRecord) Created() time.Time {
+	return time.Unix(rec.Timestamp, 0).UTC()
+}
+
+// LastUpdated returns the time at which rec was last updated, derived from its `lastTimeUpdated` property.
+// If `lastTimeUpdated` is not set then the `timestamp` property is used instead.
+
+func (rec *OpenAccessRecord) LastUpdated() time.Time {
+
+	if rec.LastTimeUpdated == 0 {
+		return rec.Created()
+	}
+
+	return time.Unix(rec.LastTimeUpdated, 0).UTC()
+}
+
+// UpdatedSince returns a boolean value indicating whether rec was updated at, or after, t.
+
+func (rec *OpenAccessRecord) UpdatedSince(t time.Time) bool {
+	return !rec.LastUpdated().Before(t)
+}
+
+// ParseTime parses str as a time. Valid values are RFC3339 date-times (2020-02-25T00:00:00Z), dates (2020-02-25),
+// which are assumed to be UTC, and Unix timestamps (1582588800) of the kind used by the `timestamp` and
+// `lastTimeUpdated` properties.
+
+func ParseTime(str string) (time.Time, error) {
+
+	t, err := time.Parse(time.RFC3339, str)
+
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse("2006-01-02", str)
+
+	if err == nil {
+		return t, nil
+	}
+
+	ts, err := strconv.ParseInt(str, 10, 64)
+
+	if err == nil {
+		return time.Unix(ts, 0).UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Unable to parse '%s' as a time", str)
+}
@@ -0,0 +1,172 @@
+// package units provides a registry of the Smithsonian units that publish data in the OpenAccess release.
+package units
+
+import (
+	"fmt"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/objecturi"
+	"github.com/jtacoma/uritemplates"
+	"sort"
+	"strings"
+)
+
+// DEFAULT_OBJECT_URL_TEMPLATE is the RFC6570 URI template used to construct an object's web page for units
+// that don't define their own. It is the object's page on the Smithsonian's collection search website.
+
+const DEFAULT_OBJECT_URL_TEMPLATE string = "https://collections.si.edu/search/detail/{+edan_url}"
+
+// Unit describes a Smithsonian unit (museum, archive, library, etc.) that publishes data in the OpenAccess release.
+
+type Unit struct {
+	// The (lower-case) unit code, for example "nasm".
+	Code string `json:"code"`
+	// The display name of the unit.
+	Name string `json:"name"`
+	// The unit's home page.
+	URL string `json:"url"`
+	// The prefix for the unit's metadata files in the OpenAccess bucket, for example "metadata/edan/nasm".
+	MetadataPrefix string `json:"metadata_prefix"`
+	// An RFC6570 URI template for an object's web page.
+	ObjectURLTemplate string `json:"object_url_template"`
+}
+
+var registry map[string]*Unit
+
+func init() {
+
+	registry = make(map[string]*Unit)
+
+	units := []*Unit{
+		&Unit{Code: "aaa", Name: "Archives of American Art", URL: "https://www.aaa.si.edu"},
+		&Unit{Code: "acah", Name: "NMAH Archives Center", URL: "https://americanhistory.si.edu/archives"},
+		&Unit{Code: "acm", Name: "Anacostia Community Museum", URL: "https://anacostia.si.edu"},
+		&Unit{Code: "cfchfolklife", Name: "Ralph Rinzler Folklife Archives and Collections", URL: "https://folklife.si.edu"},
+		&Unit{Code: "chndm", Name: "Cooper Hewitt, Smithsonian Design Museum", URL: "https://www.cooperhewitt.org"},
+		&Unit{Code: "eepa", Name: "Eliot Elisofon Photographic Archives", URL: "https://africa.si.edu/research/archives"},
+		&Unit{Code: "fbr", Name: "Smithsonian Field Book Project", URL: "https://www.si.edu/spotlight/fieldbooks"},
+		&Unit{Code: "fsg", Name: "Freer Gallery of Art and Arthur M. Sackler Gallery", URL: "https://asia.si.edu"},
+		&Unit{Code: "hac", Name: "Smithsonian Gardens", URL: "https://gardens.si.edu"},
+		&Unit{Code: "hmsg", Name: "Hirshhorn Museum and Sculpture Garden", URL: "https://hirshhorn.si.edu"},
+		&Unit{Code: "hsfa", Name: "Human Studies Film Archives", URL: "https://anthropology.si.edu/naa"},
+		&Unit{Code: "naa", Name: "National Anthropological Archives", URL: "https://anthropology.si.edu/naa"},
+		&Unit{Code: "nasm", Name: "National Air and Space Museum", URL: "https://airandspace.si.edu", ObjectURLTemplate: "https://airandspace.si.edu/collection/id/{record_id}"},
+		&Unit{Code: "nmaahc", Name: "National Museum of African American History and Culture", URL: "https://nmaahc.si.edu", ObjectURLTemplate: "https://nmaahc.si.edu/object/{record_id}"},
+		&Unit{Code: "nmafa", Name: "National Museum of African Art", URL: "https://africa.si.edu"},
+		&Unit{Code: "nmah", Name: "National Museum of American History", URL: "https://americanhistory.si.edu"},
+		&Unit{Code: "nmai", Name: "National Museum of the American Indian", URL: "https://americanindian.si.edu"},
+		&Unit{Code: "nmnhanthro", Name: "NMNH - Anthropology Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhbirds", Name: "NMNH - Vertebrate Zoology - Birds Division", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhbotany", Name: "NMNH - Botany Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnheducation", Name: "NMNH - Education & Outreach", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhento", Name: "NMNH - Entomology Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhfishes", Name: "NMNH - Vertebrate Zoology - Fishes Division", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhherps", Name: "NMNH - Vertebrate Zoology - Herpetology Division", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhinv", Name: "NMNH - Invertebrate Zoology Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhmammals", Name: "NMNH - Vertebrate Zoology - Mammals Division", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhminsci", Name: "NMNH - Mineral Sciences Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "nmnhpaleo", Name: "NMNH - Paleobiology Dept.", URL: "https://naturalhistory.si.edu"},
+		&Unit{Code: "npg", Name: "National Portrait Gallery", URL: "https://npg.si.edu"},
+		&Unit{Code: "npm", Name: "National Postal Museum", URL: "https://postalmuseum.si.edu"},
+		&Unit{Code: "nzp", Name: "Smithsonian's National Zoo", URL: "https://nationalzoo.si.edu"},
+		&Unit{Code: "saam", Name: "Smithsonian American Art Museum", URL: "https://americanart.si.edu"},
+		&Unit{Code: "sia", Name: "Smithsonian Institution Archives", URL: "https://siarchives.si.edu"},
+		&Unit{Code: "sil", Name: "Smithsonian Libraries", URL: "https://library.si.edu"},
+	}
+
+	for _, u := range units {
+
+		u.MetadataPrefix = fmt.Sprintf("%s%s", openaccess.AWS_S3_METADATA, u.Code)
+
+		if u.ObjectURLTemplate == "" {
+			u.ObjectURLTemplate = DEFAULT_OBJECT_URL_TEMPLATE
+		}
+
+		registry[u.Code] = u
+	}
+}
+
+// Units returns the list of all known units sorted by unit code.
+
+func Units() []*Unit {
+
+	codes := make([]string, 0)
+
+	for code := range registry {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	units := make([]*Unit, len(codes))
+
+	for i, code := range codes {
+		units[i] = registry[code]
+	}
+
+	return units
+}
+
+// GetUnit returns the unit whose (case-insensitive) code matches code.
+
+func GetUnit(code string) (*Unit, error) {
+
+	u, ok := registry[strings.ToLower(code)]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown unit '%s'", code)
+	}
+
+	return u, nil
+}
+
+// DefaultUnit returns a new Unit, using the default templates, for a unit code that has not been registered.
+
+func DefaultUnit(code string) *Unit {
+
+	code = strings.ToLower(code)
+
+	u := &Unit{
+		Code:              code,
+		Name:              code,
+		MetadataPrefix:    fmt.Sprintf("%s%s", openaccess.AWS_S3_METADATA, code),
+		ObjectURLTemplate: DEFAULT_OBJECT_URL_TEMPLATE,
+	}
+
+	return u
+}
+
+// GetUnitForRecord returns the unit that published rec.
+
+func GetUnitForRecord(rec *openaccess.OpenAccessRecord) (*Unit, error) {
+	return GetUnit(rec.UnitCode)
+}
+
+// ObjectURL returns the web page for rec derived from the unit's object URL template.
+
+func (u *Unit) ObjectURL(rec *openaccess.OpenAccessRecord) (string, error) {
+	return u.expand(u.ObjectURLTemplate, rec)
+}
+
+// ObjectURI returns the reversible `si://` URI for rec. See the objecturi package for details.
+
+func (u *Unit) ObjectURI(rec *openaccess.OpenAccessRecord) (string, error) {
+	return objecturi.Format(u.Code, rec.Id), nil
+}
+
+func (u *Unit) expand(template string, rec *openaccess.OpenAccessRecord) (string, error) {
+
+	t, err := uritemplates.Parse(template)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse template for unit %s, %w", u.Code, err)
+	}
+
+	values := map[string]interface{}{
+		"unit":      u.Code,
+		"id":        rec.Id,
+		"record_id": rec.Content.DescriptiveNonRepeating.RecordId,
+		"edan_url":  rec.URL,
+	}
+
+	return t.Expand(values)
+}
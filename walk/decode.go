@@ -0,0 +1,179 @@
+package walk
+
+import (
+	"context"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"sync"
+)
+
+// WalkOpenAccessRecordCallbackFunc is a callback function that receives a decoded OpenAccess record (or an error).
+// Records that can not be decoded are passed to the callback as a nil record and a *jw.WalkError error.
+
+type WalkOpenAccessRecordCallbackFunc func(context.Context, *Record, error) error
+
+type decodeJob struct {
+	ctx     context.Context
+	raw     *jw.WalkRecord
+	record  *Record
+	err     error
+	done    chan bool
+	pending *sync.WaitGroup
+}
+
+// pendingKey is the context key for the *sync.WaitGroup returned by withPending.
+
+type pendingKey struct{}
+
+// withPending returns a copy of ctx and a *sync.WaitGroup. Every record handed to a decodePool with the returned context
+// is added to the wait group until it has been dispatched. This allows a walk to wait for all the records in a file to be
+// decoded and dispatched before the file is marked as complete.
+
+func withPending(ctx context.Context) (context.Context, *sync.WaitGroup) {
+
+	wg := new(sync.WaitGroup)
+	ctx = context.WithValue(ctx, pendingKey{}, wg)
+
+	return ctx, wg
+}
+
+// decode decodes the raw record for job, if there is one.
+
+func (job *decodeJob) decode() {
+
+	if job.err != nil {
+		return
+	}
+
+	r, err := newRecord(job.raw)
+
+	if err != nil {
+
+		job.err = &jw.WalkError{
+			Path:       job.raw.Path,
+			LineNumber: job.raw.LineNumber,
+			Err:        err,
+		}
+
+		return
+	}
+
+	job.record = r
+}
+
+// decodePool decodes raw records on a pool of workers and dispatches them, and any errors, to a WalkOpenAccessRecordCallbackFunc.
+// If ordered is true then records are dispatched, sequentially, in the order they were received. Otherwise they are dispatched
+// concurrently by each worker as soon as they are decoded.
+
+type decodePool struct {
+	callback    WalkOpenAccessRecordCallbackFunc
+	ordered     bool
+	jobs        chan *decodeJob
+	pending     chan *decodeJob
+	workers_wg  *sync.WaitGroup
+	dispatch_wg *sync.WaitGroup
+}
+
+func newDecodePool(workers int, ordered bool, cb WalkOpenAccessRecordCallbackFunc) *decodePool {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &decodePool{
+		callback:    cb,
+		ordered:     ordered,
+		jobs:        make(chan *decodeJob, workers),
+		workers_wg:  new(sync.WaitGroup),
+		dispatch_wg: new(sync.WaitGroup),
+	}
+
+	for i := 0; i < workers; i++ {
+
+		p.workers_wg.Add(1)
+
+		go func() {
+
+			defer p.workers_wg.Done()
+
+			for job := range p.jobs {
+
+				job.decode()
+
+				if p.ordered {
+					close(job.done)
+					continue
+				}
+
+				p.dispatch(job)
+			}
+		}()
+	}
+
+	if ordered {
+
+		p.pending = make(chan *decodeJob, workers*2)
+		p.dispatch_wg.Add(1)
+
+		go func() {
+
+			defer p.dispatch_wg.Done()
+
+			for job := range p.pending {
+				<-job.done
+				p.dispatch(job)
+			}
+		}()
+	}
+
+	return p
+}
+
+// Callback is a WalkRecordCallbackFunc that hands rec (or err) off to the pool.
+
+func (p *decodePool) Callback(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+	job := &decodeJob{
+		ctx: ctx,
+		raw: rec,
+		err: err,
+	}
+
+	if v := ctx.Value(pendingKey{}); v != nil {
+		job.pending = v.(*sync.WaitGroup)
+		job.pending.Add(1)
+	}
+
+	if p.ordered {
+		job.done = make(chan bool)
+		p.pending <- job
+	}
+
+	p.jobs <- job
+	return nil
+}
+
+// Close waits for all the records handed to the pool to be dispatched. Callback must not be called after Close.
+
+func (p *decodePool) Close() {
+
+	close(p.jobs)
+	p.workers_wg.Wait()
+
+	if p.ordered {
+		close(p.pending)
+		p.dispatch_wg.Wait()
+	}
+}
+
+func (p *decodePool) dispatch(job *decodeJob) {
+
+	if job.pending != nil {
+		defer job.pending.Done()
+	}
+
+	if job.ctx.Err() != nil {
+		return
+	}
+
+	p.callback(job.ctx, job.record, job.err)
+}
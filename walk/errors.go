@@ -0,0 +1,287 @@
+package walk
+
+import (
+	"context"
+	"fmt"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/progress"
+	"strings"
+	"sync"
+)
+
+// FAILURE_POLICY_FAIL_FAST cancels a walk as soon as a callback returns an error.
+
+const FAILURE_POLICY_FAIL_FAST string = "fail-fast"
+
+// FAILURE_POLICY_SKIP records callback errors and continues walking. This is the default policy.
+
+const FAILURE_POLICY_SKIP string = "skip"
+
+// FAILURE_POLICY_MAX_ERRORS records callback errors and cancels a walk once WalkOptions.MaxErrors errors have been recorded.
+
+const FAILURE_POLICY_MAX_ERRORS string = "max-errors"
+
+// The maximum number of errors to include in the string representation of a WalkErrors instance.
+
+const MAX_REPORTED_ERRORS int = 10
+
+// FailurePolicies returns the list of valid failure policies.
+
+func FailurePolicies() []string {
+
+	policies := []string{
+		FAILURE_POLICY_FAIL_FAST,
+		FAILURE_POLICY_SKIP,
+		FAILURE_POLICY_MAX_ERRORS,
+	}
+
+	return policies
+}
+
+// IsValidFailurePolicy returns a boolean value indicating whether policy is a valid failure policy.
+
+func IsValidFailurePolicy(policy string) bool {
+
+	for _, p := range FailurePolicies() {
+
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WalkErrors is the aggregate error returned by WalkBucket and WalkSmithsonianRecord when one or more callbacks
+// returned an error. Each error is annotated with the path and line number of the record that triggered it.
+
+type WalkErrors struct {
+	Errors []*jw.WalkError
+	// Cancelled is true if the walk was cancelled, according to its failure policy, before it was complete.
+	Cancelled bool
+}
+
+func (e *WalkErrors) Error() string {
+
+	count := len(e.Errors)
+
+	msg := make([]string, 0)
+
+	for i, err := range e.Errors {
+
+		if i == MAX_REPORTED_ERRORS {
+			msg = append(msg, fmt.Sprintf("and %d more", count-i))
+			break
+		}
+
+		msg = append(msg, err.String())
+	}
+
+	status := "completed"
+
+	if e.Cancelled {
+		status = "cancelled"
+	}
+
+	return fmt.Sprintf("Walk %s with %d error(s): %s", status, count, strings.Join(msg, "; "))
+}
+
+// errorCollector records the errors returned by a walk's callback and cancels the walk according to its failure policy.
+// It also records the paths of all the files that produced an error, whether or not it was returned by the callback, so
+// that they are not marked as complete.
+
+type errorCollector struct {
+	policy     string
+	max_errors int
+	cancel     context.CancelFunc
+	errors     []*jw.WalkError
+	cancelled  bool
+	failed     map[string]bool
+	monitor    *progress.Monitor
+	mu         *sync.Mutex
+}
+
+func newErrorCollector(opts *WalkOptions, cancel context.CancelFunc) (*errorCollector, error) {
+
+	policy := opts.FailurePolicy
+
+	if policy == "" {
+		policy = FAILURE_POLICY_SKIP
+	}
+
+	if !IsValidFailurePolicy(policy) {
+		return nil, fmt.Errorf("Invalid failure policy '%s'", policy)
+	}
+
+	max_errors := opts.MaxErrors
+
+	if max_errors < 1 {
+		max_errors = 1
+	}
+
+	c := &errorCollector{
+		policy:     policy,
+		max_errors: max_errors,
+		cancel:     cancel,
+		errors:     make([]*jw.WalkError, 0),
+		failed:     make(map[string]bool),
+		monitor:    opts.Progress,
+		mu:         new(sync.Mutex),
+	}
+
+	return c, nil
+}
+
+// callback returns a WalkRecordCallbackFunc that invokes cb and records any error it returns.
+
+func (c *errorCollector) callback(cb WalkRecordCallbackFunc) WalkRecordCallbackFunc {
+
+	return func(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+		cb_err := cb(ctx, rec, err)
+
+		if cb_err != nil {
+			c.add(ctx, rec, err, cb_err)
+		} else if err != nil {
+			c.fail(errorPath(ctx, rec, err))
+		}
+
+		return cb_err
+	}
+}
+
+// recordCallback returns a WalkOpenAccessRecordCallbackFunc that invokes cb and records any error it returns.
+
+func (c *errorCollector) recordCallback(cb WalkOpenAccessRecordCallbackFunc) WalkOpenAccessRecordCallbackFunc {
+
+	return func(ctx context.Context, rec *Record, err error) error {
+
+		cb_err := cb(ctx, rec, err)
+
+		if cb_err != nil {
+
+			var jw_rec *jw.WalkRecord
+
+			if rec != nil {
+
+				jw_rec = &jw.WalkRecord{
+					Path:       rec.Path,
+					LineNumber: rec.LineNumber,
+				}
+			}
+
+			c.add(ctx, jw_rec, err, cb_err)
+
+		} else if err != nil {
+			c.fail(errorPath(ctx, nil, err))
+		}
+
+		return cb_err
+	}
+}
+
+func (c *errorCollector) add(ctx context.Context, rec *jw.WalkRecord, err error, cb_err error) {
+
+	path := errorPath(ctx, rec, err)
+
+	e, ok := cb_err.(*jw.WalkError)
+
+	if !ok {
+
+		e = &jw.WalkError{
+			Path: path,
+			Err:  cb_err,
+		}
+
+		if rec != nil {
+			e.LineNumber = rec.LineNumber
+		} else if walk_err, ok := err.(*jw.WalkError); ok {
+			e.LineNumber = walk_err.LineNumber
+		}
+	}
+
+	c.monitor.Error()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed[path] = true
+
+	// Errors returned by callbacks that were already in flight when the walk was cancelled are still recorded
+
+	c.errors = append(c.errors, e)
+
+	if c.cancelled {
+		return
+	}
+
+	switch c.policy {
+	case FAILURE_POLICY_FAIL_FAST:
+		c.cancelled = true
+	case FAILURE_POLICY_MAX_ERRORS:
+		c.cancelled = len(c.errors) >= c.max_errors
+	default:
+		// pass
+	}
+
+	if c.cancelled {
+		c.cancel()
+	}
+}
+
+// fail records that path produced an error.
+
+func (c *errorCollector) fail(path string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed[path] = true
+}
+
+// hasFailed returns a boolean value indicating whether path produced an error.
+
+func (c *errorCollector) hasFailed(path string) bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.failed[path]
+}
+
+// err returns a *WalkErrors instance if any errors have been recorded or nil.
+
+func (c *errorCollector) err() error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.errors) == 0 {
+		return nil
+	}
+
+	e := &WalkErrors{
+		Errors:    c.errors,
+		Cancelled: c.cancelled,
+	}
+
+	return e
+}
+
+// errorPath returns the path of the file that rec (or err) belongs to. If neither rec nor err identify a path then the
+// path of the file currently being walked, if any, is returned.
+
+func errorPath(ctx context.Context, rec *jw.WalkRecord, err error) string {
+
+	if rec != nil {
+		return rec.Path
+	}
+
+	walk_err, ok := err.(*jw.WalkError)
+
+	if ok && walk_err.Path != "" {
+		return walk_err.Path
+	}
+
+	return pathFromContext(ctx)
+}
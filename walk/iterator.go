@@ -0,0 +1,184 @@
+package walk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess"
+	"gocloud.dev/blob"
+	"io"
+	"sync"
+)
+
+// Record is a decoded OpenAccess record and its location in a bucket.
+
+type Record struct {
+	// The path of the file containing the record.
+	Path string
+	// The line number of the record in Path.
+	LineNumber int
+	// The raw bytes of the record.
+	Body []byte
+	// The decoded record.
+	OpenAccessRecord *openaccess.OpenAccessRecord
+}
+
+// Iterator is a pull-style alternative to WalkBucket. Rather than dispatching records to a callback it yields
+// decoded records, one at a time, from its Next method. For example:
+//
+//	iter, _ := walk.NewIterator(ctx, opts, bucket)
+//	defer iter.Close()
+//
+//	for {
+//		rec, err := iter.Next(ctx)
+//
+//		if err == io.EOF {
+//			break
+//		}
+//
+//		if err != nil {
+//			return err
+//		}
+//
+//		fmt.Println(rec.OpenAccessRecord.Id)
+//	}
+//
+// Records are read and decoded concurrently in the background but the walk does not advance further than a small
+// buffer ahead of the consumer. If WalkOptions.Ordered is true records are yielded sorted by path and line number.
+
+type Iterator struct {
+	records chan *Record
+	cancel  context.CancelFunc
+	done    chan bool
+	err     error
+	once    *sync.Once
+}
+
+// NewIterator starts walking bucket, according to opts, and returns a new Iterator instance for consuming its records.
+// The opts.Callback and opts.RecordCallback properties are ignored. Records that can not be decoded are treated as
+// callback errors and handled according to opts.FailurePolicy.
+
+func NewIterator(ctx context.Context, opts *WalkOptions, bucket *blob.Bucket) (*Iterator, error) {
+
+	if opts.FailurePolicy != "" && !IsValidFailurePolicy(opts.FailurePolicy) {
+		return nil, fmt.Errorf("Invalid failure policy '%s'", opts.FailurePolicy)
+	}
+
+	err := validateWalkOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	buffer_size := opts.Workers
+
+	if buffer_size < 1 {
+		buffer_size = 1
+	}
+
+	it := &Iterator{
+		records: make(chan *Record, buffer_size),
+		cancel:  cancel,
+		done:    make(chan bool),
+		once:    new(sync.Once),
+	}
+
+	cb := func(ctx context.Context, r *Record, err error) error {
+
+		if err != nil {
+
+			if jw.IsEOFError(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case it.records <- r:
+			return nil
+		}
+	}
+
+	walk_opts := *opts
+	walk_opts.RecordCallback = cb
+
+	go func() {
+
+		it.err = WalkBucket(ctx, &walk_opts, bucket)
+
+		close(it.records)
+		close(it.done)
+	}()
+
+	return it, nil
+}
+
+// Next returns the next record in the walk. Once all the records have been consumed it returns io.EOF or, if
+// any records failed, a *WalkErrors instance. If ctx is cancelled before a record is available ctx.Err() is returned
+// and the iterator may still be used.
+
+func (it *Iterator) Next(ctx context.Context) (*Record, error) {
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r, ok := <-it.records:
+
+		if ok {
+			return r, nil
+		}
+
+		if it.err != nil {
+			return nil, it.err
+		}
+
+		return nil, io.EOF
+	}
+}
+
+// Close cancels the walk, if it is still in progress, and waits for it to finish. Subsequent calls to Next return
+// io.EOF or, if any records failed before the walk was cancelled, a *WalkErrors instance.
+
+func (it *Iterator) Close() error {
+
+	it.once.Do(func() {
+
+		it.cancel()
+
+		// Drain any buffered records so that nothing is left blocking
+
+		for range it.records {
+			// pass
+		}
+
+		<-it.done
+	})
+
+	return nil
+}
+
+func newRecord(rec *jw.WalkRecord) (*Record, error) {
+
+	var object *openaccess.OpenAccessRecord
+
+	err := json.Unmarshal(rec.Body, &object)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode record, %w", err)
+	}
+
+	r := &Record{
+		Path:             rec.Path,
+		LineNumber:       rec.LineNumber,
+		Body:             rec.Body,
+		OpenAccessRecord: object,
+	}
+
+	return r, nil
+}
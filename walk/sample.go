@@ -0,0 +1,179 @@
+package walk
+
+import (
+	"container/heap"
+	"context"
+	"encoding/binary"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
+	"hash/fnv"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// sampler implements reservoir sampling over the records in a walk. Rather than drawing random numbers as records
+// arrive, which would make the sample depend on the (non-deterministic) order in which files are read, each record
+// is assigned a pseudo-random priority derived from the seed and its path and line number. The records with the
+// lowest priorities are kept which yields a uniform random sample that is reproducible for a given seed. Paths are
+// reduced to their shard key so that the same records are sampled from a bucket and its (compressed) clone.
+
+type sampler struct {
+	size     int
+	per_unit bool
+	seed     int64
+	strata   map[string]*reservoir
+	mu       *sync.Mutex
+}
+
+func newSampler(opts *WalkOptions) *sampler {
+
+	s := &sampler{
+		size:     opts.SampleSize,
+		per_unit: opts.SamplePerUnit,
+		seed:     opts.SampleSeed,
+		strata:   make(map[string]*reservoir),
+		mu:       new(sync.Mutex),
+	}
+
+	return s
+}
+
+// callback returns a WalkRecordCallbackFunc that adds records to the sample. Errors are passed directly to cb.
+
+func (s *sampler) callback(cb WalkRecordCallbackFunc) WalkRecordCallbackFunc {
+
+	return func(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+		if err != nil {
+			return cb(ctx, rec, err)
+		}
+
+		s.add(rec)
+		return nil
+	}
+}
+
+func (s *sampler) add(rec *jw.WalkRecord) {
+
+	stratum := ""
+
+	if s.per_unit {
+		// Metadata files are stored as metadata/edan/{UNIT}/{HASH}.txt
+		stratum = filepath.Base(filepath.Dir(rec.Path))
+	}
+
+	item := &sampleItem{
+		record:   rec,
+		priority: s.priority(rec),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.strata[stratum]
+
+	if !ok {
+		r = new(reservoir)
+		s.strata[stratum] = r
+	}
+
+	if r.Len() < s.size {
+		heap.Push(r, item)
+		return
+	}
+
+	if item.priority < (*r)[0].priority {
+		(*r)[0] = item
+		heap.Fix(r, 0)
+	}
+}
+
+// dispatch passes the sampled records, sorted by path and then line number, to cb.
+
+func (s *sampler) dispatch(ctx context.Context, cb WalkRecordCallbackFunc) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]*jw.WalkRecord, 0)
+
+	for _, r := range s.strata {
+
+		for _, item := range *r {
+			records = append(records, item.record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+
+		if records[i].Path != records[j].Path {
+			return records[i].Path < records[j].Path
+		}
+
+		return records[i].LineNumber < records[j].LineNumber
+	})
+
+	for _, rec := range records {
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		rec_ctx := context.WithValue(ctx, jw.CONTEXT_PATH, rec.Path)
+		cb(rec_ctx, rec, nil)
+	}
+}
+
+func (s *sampler) priority(rec *jw.WalkRecord) uint64 {
+
+	buf := make([]byte, 16)
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(s.seed))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(rec.LineNumber))
+
+	h := fnv.New64a()
+	h.Write(buf)
+	h.Write([]byte(shard.Key(rec.Path)))
+
+	// Apply the splitmix64 finalizer so that priorities are evenly distributed
+
+	z := h.Sum64()
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+type sampleItem struct {
+	record   *jw.WalkRecord
+	priority uint64
+}
+
+// reservoir is a max-heap of sampleItem instances, ordered by priority, so that the item with the highest
+// priority is always the first to be replaced.
+
+type reservoir []*sampleItem
+
+func (r reservoir) Len() int {
+	return len(r)
+}
+
+func (r reservoir) Less(i, j int) bool {
+	return r[i].priority > r[j].priority
+}
+
+func (r reservoir) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+func (r *reservoir) Push(x interface{}) {
+	*r = append(*r, x.(*sampleItem))
+}
+
+func (r *reservoir) Pop() interface{} {
+	old := *r
+	n := len(old)
+	item := old[n-1]
+	*r = old[0 : n-1]
+	return item
+}
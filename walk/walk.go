@@ -2,11 +2,22 @@ package walk
 
 import (
 	"context"
+	"fmt"
 	"github.com/aaronland/go-json-query"
 	jw "github.com/aaronland/go-jsonl/walk"
 	"github.com/aaronland/go-smithsonian-openaccess"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
+	"github.com/aaronland/go-smithsonian-openaccess/codec"
+	"github.com/aaronland/go-smithsonian-openaccess/incremental"
+	"github.com/aaronland/go-smithsonian-openaccess/progress"
+	"github.com/aaronland/go-smithsonian-openaccess/shard"
 	"gocloud.dev/blob"
+	"io"
 	_ "log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type WalkOptions struct {
@@ -16,53 +27,403 @@ type WalkOptions struct {
 	FormatJSON   bool
 	QuerySet     *query.QuerySet
 	Callback     WalkRecordCallbackFunc
-	IsBzip       bool
-	Filter       jw.WalkFilterFunc
+	// An optional callback function that receives decoded OpenAccess records. If present it is used instead of Callback.
+	RecordCallback WalkOpenAccessRecordCallbackFunc
+	// The number of workers used to decode records when RecordCallback is present. Default is the number of CPUs.
+	DecodeWorkers int
+	IsBzip        bool
+	// The name of a registered codec (see the codec package) used to decompress every file, regardless of its extension.
+	// If empty then files are decompressed according to their extension and files with no registered extension are not
+	// decompressed.
+	Codec  string
+	Filter jw.WalkFilterFunc
+	// An optional checkpoint.Checkpoint instance used to skip metadata files that have already been fully
+	// processed and to record the metadata files that are fully processed, without errors, during this walk.
+	Checkpoint checkpoint.Checkpoint
+	// An optional function invoked with the path of each file whose records have all been dispatched, without errors, before
+	// it is marked as complete in Checkpoint and State. This can be used to flush buffered output. If it returns an error
+	// the file is not marked as complete.
+	CompleteCallback func(context.Context, string) error
+	// If true records are dispatched to Callback sequentially, sorted by path and then line number.
+	Ordered bool
+	// The maximum number of records to buffer for each file when Ordered is true. Default is DEFAULT_ORDERED_BUFFER_SIZE.
+	OrderedBufferSize int
+	// One of the FAILURE_POLICY_ constants describing what to do when Callback returns an error. Default is FAILURE_POLICY_SKIP.
+	FailurePolicy string
+	// The number of errors after which a walk is cancelled when FailurePolicy is FAILURE_POLICY_MAX_ERRORS.
+	MaxErrors int
+	// The (zero-based) index of the shard to walk when ShardCount is greater than 1. See the shard package for details.
+	ShardIndex int
+	// The total number of shards that metadata files are partitioned in to. If less than or equal to 1 then all files are walked.
+	ShardCount int
+	// If greater than 0 only a uniform random sample of this many records is dispatched to Callback (or RecordCallback), sorted
+	// by path and then line number, once all the records in the walk have been read. Errors are still dispatched as they occur.
+	SampleSize int
+	// If true then SampleSize records are sampled for each unit (derived from the path of each metadata file) rather than for the walk as a whole.
+	SamplePerUnit bool
+	// The seed used to select sampled records. The same seed will always select the same records from the same data.
+	SampleSeed int64
+	// An optional progress.Monitor instance used to publish the progress of the walk.
+	Progress *progress.Monitor
+	// An optional incremental.State instance. If present only files whose MD5 hash, modification time or size differ
+	// from the state recorded by a previous walk are walked and the state of each file that is fully processed, without errors, is updated.
+	State *incremental.State
 }
 
+// DEFAULT_ORDERED_BUFFER_SIZE is the default maximum number of records to buffer for each file in an ordered walk.
+
+const DEFAULT_ORDERED_BUFFER_SIZE int = 1000
+
 type WalkRecordCallbackFunc func(context.Context, *jw.WalkRecord, error) error
 
+// WalkBucket walks all the line-delimited JSON files in bucket, starting at opts.URI, dispatching each record (or error)
+// to opts.Callback. Files are processed concurrently, up to a maximum of opts.Workers files at a time. Unless opts.Ordered
+// is true records from different files may be dispatched concurrently and in any order. Files ending in the extension of
+// a registered codec (for example ".bz2" or ".zst") are decompressed using that codec. Errors returned by opts.Callback are handled according to opts.FailurePolicy and,
+// if there were any, returned as a *WalkErrors instance once the walk is complete.
+
 func WalkBucket(ctx context.Context, opts *WalkOptions, bucket *blob.Bucket) error {
 
+	err := validateWalkOptions(opts)
+
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	cb := opts.Callback
+	collector, err := newErrorCollector(opts, cancel)
 
-	jw_record_ch := make(chan *jw.WalkRecord)
-	jw_error_ch := make(chan *jw.WalkError)
+	if err != nil {
+		return err
+	}
 
-	jw_opts := &jw.WalkOptions{
-		URI:           opts.URI,
-		Workers:       opts.Workers,
-		RecordChannel: jw_record_ch,
-		ErrorChannel:  jw_error_ch,
-		FormatJSON:    opts.FormatJSON,
-		ValidateJSON:  opts.ValidateJSON,
-		QuerySet:      opts.QuerySet,
-		IsBzip:        opts.IsBzip,
-		Filter:        opts.Filter,
+	walk_opts, pool := prepareWalkOptions(opts, collector)
+
+	var s *sampler
+	dispatch := walk_opts.Callback
+
+	if opts.SampleSize > 0 {
+		s = newSampler(opts)
+		walk_opts.Callback = s.callback(dispatch)
+	}
+
+	if walk_opts.Ordered {
+		walkBucketOrdered(ctx, walk_opts, collector, bucket)
+	} else {
+		walkBucketUnordered(ctx, walk_opts, collector, bucket)
+	}
+
+	if s != nil {
+		s.dispatch(ctx, dispatch)
+	}
+
+	if pool != nil {
+		pool.Close()
+	}
+
+	return collector.err()
+}
+
+// validateWalkOptions ensures that opts does not contain incompatible properties.
+
+func validateWalkOptions(opts *WalkOptions) error {
+
+	err := shard.Validate(opts.ShardIndex, opts.ShardCount)
+
+	if err != nil {
+		return err
+	}
+
+	// Files are marked as complete as soon as they have been read but sampled records are only dispatched
+	// once the whole walk is complete so a resumed (or incremental) walk would produce a different sample.
+
+	if opts.SampleSize > 0 && opts.Checkpoint != nil {
+		return fmt.Errorf("Sampling can not be used with checkpoints")
+	}
+
+	if opts.SampleSize > 0 && opts.State != nil {
+		return fmt.Errorf("Sampling can not be used with incremental state")
+	}
+
+	if opts.Codec != "" {
+
+		_, err := codec.NewCodec(opts.Codec)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prepareWalkOptions returns a copy of opts whose Callback records errors in collector. If opts.RecordCallback is present
+// then Callback hands records off to a decodePool instance, which is also returned and must be closed once the walk is complete.
+
+func prepareWalkOptions(opts *WalkOptions, collector *errorCollector) (*WalkOptions, *decodePool) {
+
+	walk_opts := *opts
+
+	if opts.RecordCallback == nil {
+		walk_opts.Callback = collector.callback(opts.Callback)
+		return &walk_opts, nil
 	}
 
+	workers := opts.DecodeWorkers
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	// Sampled records are dispatched in order so they need to be decoded in order
+
+	ordered := opts.Ordered || opts.SampleSize > 0
+
+	pool := newDecodePool(workers, ordered, collector.recordCallback(opts.RecordCallback))
+	walk_opts.Callback = pool.Callback
+
+	return &walk_opts, pool
+}
+
+// walkBucketUnordered processes the files in bucket concurrently dispatching their records to opts.Callback as soon as they are read.
+
+func walkBucketUnordered(ctx context.Context, opts *WalkOptions, collector *errorCollector, bucket *blob.Bucket) {
+
+	throttle := newThrottle(opts.Workers)
+	wg := new(sync.WaitGroup)
+
+	list_cb := func(ctx context.Context, path string) {
+
+		<-throttle
+
+		wg.Add(1)
+
+		go func(path string) {
+
+			defer func() {
+				wg.Done()
+				throttle <- true
+			}()
+
+			// Records handed off to a decodePool must be dispatched before the file is marked as complete
+
+			file_ctx, pending := withPending(ctx)
+
+			walkFile(file_ctx, opts, bucket, path, opts.Callback)
+			pending.Wait()
+
+			markComplete(ctx, opts, collector, path)
+
+		}(path)
+	}
+
+	listBucket(ctx, opts, bucket, list_cb)
+	wg.Wait()
+}
+
+type orderedItem struct {
+	record *jw.WalkRecord
+	err    error
+}
+
+type orderedQueue struct {
+	path  string
+	items chan *orderedItem
+}
+
+// walkBucketOrdered lists all the files in bucket, sorts them by path and then processes them concurrently while
+// dispatching their records to opts.Callback, sequentially, in order. Each file buffers a maximum of opts.OrderedBufferSize
+// records and at most opts.Workers files are buffered ahead of the file currently being dispatched.
+
+func walkBucketOrdered(ctx context.Context, opts *WalkOptions, collector *errorCollector, bucket *blob.Bucket) {
+
+	paths := make([]string, 0)
+
+	list_cb := func(ctx context.Context, path string) {
+		paths = append(paths, path)
+	}
+
+	listBucket(ctx, opts, bucket, list_cb)
+
+	sort.Strings(paths)
+
+	workers := opts.Workers
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	buffer_size := opts.OrderedBufferSize
+
+	if buffer_size < 1 {
+		buffer_size = DEFAULT_ORDERED_BUFFER_SIZE
+	}
+
+	throttle := newThrottle(workers)
+	queues_ch := make(chan *orderedQueue, workers)
+
 	go func() {
 
-		for {
+		defer close(queues_ch)
+
+		for _, path := range paths {
+
 			select {
 			case <-ctx.Done():
 				return
-			case err := <-jw_error_ch:
-				cb(ctx, nil, err)
-			case rec := <-jw_record_ch:
-				cb(ctx, rec, nil)
-			default:
+			case <-throttle:
 				// pass
 			}
+
+			q := &orderedQueue{
+				path:  path,
+				items: make(chan *orderedItem, buffer_size),
+			}
+
+			go func(q *orderedQueue) {
+
+				defer func() {
+					close(q.items)
+					throttle <- true
+				}()
+
+				cb := func(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+					q.items <- &orderedItem{
+						record: rec,
+						err:    err,
+					}
+
+					return nil
+				}
+
+				walkFile(ctx, opts, bucket, q.path, cb)
+
+			}(q)
+
+			queues_ch <- q
 		}
 	}()
 
-	return jw.WalkBucket(ctx, jw_opts, bucket)
+	for q := range queues_ch {
+
+		q_ctx, pending := withPending(ctx)
+
+		for item := range q.items {
+
+			if ctx.Err() != nil {
+				continue
+			}
+
+			opts.Callback(q_ctx, item.record, item.err)
+		}
+
+		pending.Wait()
+
+		markComplete(ctx, opts, collector, q.path)
+	}
 }
 
+// listBucket recursively lists all the files in bucket, starting at opts.URI, and passes the path of every file
+// that should be walked to cb.
+
+func listBucket(ctx context.Context, opts *WalkOptions, bucket *blob.Bucket, cb func(context.Context, string)) {
+
+	opts.Progress.StartListing()
+	defer opts.Progress.EndListing()
+
+	var walkFunc func(context.Context, *blob.Bucket, string) error
+
+	walkFunc = func(ctx context.Context, bucket *blob.Bucket, prefix string) error {
+
+		iter := bucket.List(&blob.ListOptions{
+			Delimiter: "/",
+			Prefix:    prefix,
+		})
+
+		for {
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			obj, err := iter.Next(ctx)
+
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				dispatchError(ctx, opts.Callback, prefix, 0, err)
+				return nil
+			}
+
+			if obj.IsDir {
+
+				err = walkFunc(ctx, bucket, obj.Key)
+
+				if err != nil {
+					dispatchError(ctx, opts.Callback, obj.Key, 0, err)
+				}
+
+				continue
+			}
+
+			if obj.Size == 0 {
+				continue
+			}
+
+			if opts.Filter != nil {
+
+				if !opts.Filter(ctx, obj.Key) {
+					continue
+				}
+			}
+
+			// trailing slashes confuse Go Cloud...
+
+			path := strings.TrimRight(obj.Key, "/")
+
+			if !shard.Contains(path, opts.ShardIndex, opts.ShardCount) {
+				continue
+			}
+
+			if opts.Checkpoint != nil {
+
+				complete, err := opts.Checkpoint.IsComplete(ctx, path)
+
+				if err != nil {
+					dispatchError(ctx, opts.Callback, path, 0, err)
+					continue
+				}
+
+				if complete {
+					continue
+				}
+			}
+
+			if opts.State != nil {
+
+				if !opts.State.Observe(incremental.NewFileState(path, obj)) {
+					continue
+				}
+			}
+
+			opts.Progress.FileListed(obj.Size)
+
+			cb(ctx, path)
+		}
+
+		return nil
+	}
+
+	walkFunc(ctx, bucket, opts.URI)
+}
+
+// WalkSmithsonianRecord walks the records in a single (OpenAccess metadata) file. Errors are handled the same way as WalkBucket.
+
 func WalkSmithsonianRecord(ctx context.Context, opts *WalkOptions, bucket *blob.Bucket, uri string) error {
 
 	if !openaccess.IsMetaDataFile(uri) {
@@ -72,15 +433,164 @@ func WalkSmithsonianRecord(ctx context.Context, opts *WalkOptions, bucket *blob.
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	fh, err := bucket.NewReader(ctx, uri, nil)
+	collector, err := newErrorCollector(opts, cancel)
 
 	if err != nil {
 		return err
 	}
 
+	walk_opts, pool := prepareWalkOptions(opts, collector)
+
+	walkFile(ctx, walk_opts, bucket, uri, walk_opts.Callback)
+
+	if pool != nil {
+		pool.Close()
+	}
+
+	markComplete(ctx, walk_opts, collector, uri)
+
+	return collector.err()
+}
+
+// walkFile walks the records in a single file dispatching them to cb.
+
+func walkFile(ctx context.Context, opts *WalkOptions, bucket *blob.Bucket, path string, cb WalkRecordCallbackFunc) {
+
+	fh, err := bucket.NewReader(ctx, path, nil)
+
+	if err != nil {
+		dispatchError(ctx, cb, path, 0, err)
+		return
+	}
+
 	defer fh.Close()
 
-	cb := opts.Callback
+	ctx = context.WithValue(ctx, jw.CONTEXT_PATH, path)
+
+	c, err := fileCodec(opts, path)
+
+	if err != nil {
+		dispatchError(ctx, cb, path, 0, err)
+		return
+	}
+
+	var r io.Reader = fh
+
+	if opts.Progress != nil {
+
+		r = &progressReader{
+			reader:  fh,
+			monitor: opts.Progress,
+		}
+	}
+
+	walkReader(ctx, opts, c, r, cb)
+}
+
+// fileCodec returns the codec used to decompress path or nil if path is not compressed.
+
+func fileCodec(opts *WalkOptions, path string) (codec.Codec, error) {
+
+	name := opts.Codec
+
+	if name == "" && opts.IsBzip {
+		name = codec.BZIP2
+	}
+
+	if name != "" {
+		return codec.NewCodec(name)
+	}
+
+	c, ok := codec.CodecForPath(path)
+
+	if !ok {
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+// markComplete records that path has been processed in opts.Progress (if present) and, unless ctx has been cancelled
+// or path produced an error, invokes opts.CompleteCallback and marks path as complete in opts.State and opts.Checkpoint (if present).
+
+func markComplete(ctx context.Context, opts *WalkOptions, collector *errorCollector, path string) {
+
+	opts.Progress.FileCompleted()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	if collector.hasFailed(path) {
+		return
+	}
+
+	if opts.CompleteCallback != nil {
+
+		err := opts.CompleteCallback(ctx, path)
+
+		if err != nil {
+			dispatchError(ctx, opts.Callback, path, 0, err)
+			return
+		}
+	}
+
+	if opts.State != nil {
+		opts.State.MarkComplete(path)
+	}
+
+	if opts.Checkpoint == nil {
+		return
+	}
+
+	err := opts.Checkpoint.MarkComplete(ctx, path)
+
+	if err != nil {
+		dispatchError(ctx, opts.Callback, path, 0, err)
+	}
+}
+
+func newThrottle(workers int) chan bool {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	throttle := make(chan bool, workers)
+
+	for i := 0; i < workers; i++ {
+		throttle <- true
+	}
+
+	return throttle
+}
+
+// walkReader hands fh off to the go-jsonl WalkReader method and dispatches the records (and errors) it produces
+// to cb. It does not return until every record has been dispatched.
+
+func walkReader(ctx context.Context, opts *WalkOptions, c codec.Codec, fh io.Reader, cb WalkRecordCallbackFunc) {
+
+	// Decompression happens here, rather than in WalkReader, so that read errors can be detected and
+	// dispatched. WalkReader will otherwise keep trying to read from a broken stream.
+
+	if c != nil {
+
+		dr, err := c.NewReader(fh)
+
+		if err != nil {
+			dispatchError(ctx, cb, pathFromContext(ctx), 0, err)
+			return
+		}
+
+		defer dr.Close()
+
+		fh = dr
+	}
+
+	cr := &contextReader{
+		ctx:    ctx,
+		reader: fh,
+	}
 
 	jw_record_ch := make(chan *jw.WalkRecord)
 	jw_error_ch := make(chan *jw.WalkError)
@@ -93,25 +603,107 @@ func WalkSmithsonianRecord(ctx context.Context, opts *WalkOptions, bucket *blob.
 		FormatJSON:    opts.FormatJSON,
 		ValidateJSON:  opts.ValidateJSON,
 		QuerySet:      opts.QuerySet,
-		IsBzip:        opts.IsBzip,
+		IsBzip:        false,
 	}
 
+	done_ch := make(chan bool)
+
 	go func() {
+		jw.WalkReader(ctx, jw_opts, cr)
+		close(done_ch)
+	}()
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case err := <-jw_error_ch:
+	// Keep draining the channels even if the context has been cancelled so that
+	// WalkReader is never left blocking on a send
+
+	for {
+		select {
+		case <-done_ch:
+
+			if cr.err != nil && ctx.Err() == nil {
+				dispatchError(ctx, cb, pathFromContext(ctx), 0, cr.err)
+			}
+
+			return
+		case err := <-jw_error_ch:
+
+			if ctx.Err() == nil {
 				cb(ctx, nil, err)
-			case rec := <-jw_record_ch:
+			}
+
+		case rec := <-jw_record_ch:
+
+			if ctx.Err() == nil {
+				opts.Progress.RecordRead()
 				cb(ctx, rec, nil)
-			default:
-				// pass
 			}
 		}
-	}()
+	}
+}
 
-	jw.WalkReader(ctx, jw_opts, fh)
-	return nil
+func dispatchError(ctx context.Context, cb WalkRecordCallbackFunc, path string, lineno int, err error) {
+
+	e := &jw.WalkError{
+		Path:       path,
+		LineNumber: lineno,
+		Err:        err,
+	}
+
+	cb(ctx, nil, e)
+}
+
+func pathFromContext(ctx context.Context) string {
+
+	v := ctx.Value(jw.CONTEXT_PATH)
+
+	if v == nil {
+		return ""
+	}
+
+	return v.(string)
+}
+
+// contextReader is an io.Reader that reports io.EOF once its context has been cancelled. This is necessary
+// because the go-jsonl WalkReader method does not stop reading when its context is cancelled. Any other error
+// is recorded and reported as io.ErrUnexpectedEOF, which causes WalkReader to stop reading.
+
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+	err    error
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+
+	if r.ctx.Err() != nil {
+		return 0, io.EOF
+	}
+
+	if r.err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n, err := r.reader.Read(p)
+
+	if err != nil && err != io.EOF {
+		r.err = err
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// progressReader is an io.Reader that records the number of bytes read in a progress.Monitor.
+
+type progressReader struct {
+	reader  io.Reader
+	monitor *progress.Monitor
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+
+	n, err := r.reader.Read(p)
+	r.monitor.BytesRead(int64(n))
+
+	return n, err
 }
@@ -0,0 +1,554 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jw "github.com/aaronland/go-jsonl/walk"
+	"github.com/aaronland/go-smithsonian-openaccess/checkpoint"
+	"gocloud.dev/blob"
+	_ "gocloud.dev/blob/fileblob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The number of records in each fixture file.
+
+const FIXTURE_RECORDS int = 5
+
+// The line number of the record in BAD_FIXTURE_PATH that can not be decoded.
+
+const BAD_FIXTURE_LINE int = 3
+
+const BAD_FIXTURE_PATH string = "metadata/edan/chndm/01.txt"
+
+var fixture_paths = []string{
+	"metadata/edan/chndm/00.txt",
+	"metadata/edan/nasm/00.txt",
+	"metadata/edan/nasm/01.txt",
+	"metadata/edan/nasm/02.txt",
+}
+
+type walkedRecord struct {
+	path   string
+	lineno int
+}
+
+// newFixtureBucket writes FIXTURE_RECORDS records to each of fixture_paths (and, if bad is true, BAD_FIXTURE_PATH) in a
+// temporary directory and returns a fileblob bucket for it along with a function to remove it.
+
+func newFixtureBucket(t *testing.T, bad bool) (*blob.Bucket, func()) {
+
+	dir, err := ioutil.TempDir("", "walk")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	paths := fixture_paths
+
+	if bad {
+		paths = append([]string{BAD_FIXTURE_PATH}, paths...)
+	}
+
+	for _, path := range paths {
+
+		unit := filepath.Base(filepath.Dir(path))
+		lines := make([]string, FIXTURE_RECORDS)
+
+		for i := 0; i < FIXTURE_RECORDS; i++ {
+
+			id := fmt.Sprintf("%s_%s_%d", unit, strings.TrimSuffix(filepath.Base(path), ".txt"), i+1)
+			lines[i] = fmt.Sprintf(`{"id":"edanmdm-%s","unitCode":"%s","type":"edanmdm","content":{"descriptiveNonRepeating":{"record_ID":"%s"}}}`, id, strings.ToUpper(unit), id)
+
+			if path == BAD_FIXTURE_PATH && i+1 == BAD_FIXTURE_LINE {
+				lines[i] = `{"id": bogus}`
+			}
+		}
+
+		abs_path := filepath.Join(dir, path)
+
+		err := os.MkdirAll(filepath.Dir(abs_path), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create fixture directory, %v", err)
+		}
+
+		err = ioutil.WriteFile(abs_path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write fixture, %v", err)
+		}
+	}
+
+	bucket, err := blob.OpenBucket(context.Background(), fmt.Sprintf("file://%s?metadata=skip", dir))
+
+	if err != nil {
+		t.Fatalf("Failed to open bucket, %v", err)
+	}
+
+	cleanup := func() {
+		bucket.Close()
+		os.RemoveAll(dir)
+	}
+
+	return bucket, cleanup
+}
+
+// recorder collects the records dispatched to a callback.
+
+type recorder struct {
+	records []*walkedRecord
+	mu      *sync.Mutex
+}
+
+func newRecorder() *recorder {
+
+	r := &recorder{
+		records: make([]*walkedRecord, 0),
+		mu:      new(sync.Mutex),
+	}
+
+	return r
+}
+
+func (r *recorder) add(path string, lineno int) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, &walkedRecord{path, lineno})
+}
+
+func (r *recorder) callback(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+	if err != nil {
+		return err
+	}
+
+	r.add(rec.Path, rec.LineNumber)
+	return nil
+}
+
+func (r *recorder) recordCallback(ctx context.Context, rec *Record, err error) error {
+
+	if err != nil {
+		return err
+	}
+
+	r.add(rec.Path, rec.LineNumber)
+	return nil
+}
+
+func isSorted(records []*walkedRecord) bool {
+
+	return sort.SliceIsSorted(records, func(i, j int) bool {
+
+		if records[i].path != records[j].path {
+			return records[i].path < records[j].path
+		}
+
+		return records[i].lineno < records[j].lineno
+	})
+}
+
+func TestWalkBucketUnordered(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, false)
+	defer cleanup()
+
+	r := newRecorder()
+
+	opts := &WalkOptions{
+		URI:      "metadata/edan/",
+		Workers:  4,
+		Callback: r.callback,
+	}
+
+	err := WalkBucket(ctx, opts, bucket)
+
+	if err != nil {
+		t.Fatalf("Failed to walk bucket, %v", err)
+	}
+
+	expected := len(fixture_paths) * FIXTURE_RECORDS
+
+	if len(r.records) != expected {
+		t.Fatalf("Expected %d records but got %d", expected, len(r.records))
+	}
+}
+
+func TestWalkBucketOrdered(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, false)
+	defer cleanup()
+
+	for _, decode := range []bool{false, true} {
+
+		r := newRecorder()
+
+		opts := &WalkOptions{
+			URI:               "metadata/edan/",
+			Workers:           4,
+			Ordered:           true,
+			OrderedBufferSize: 2,
+		}
+
+		if decode {
+			opts.RecordCallback = r.recordCallback
+			opts.DecodeWorkers = 4
+		} else {
+			opts.Callback = r.callback
+		}
+
+		err := WalkBucket(ctx, opts, bucket)
+
+		if err != nil {
+			t.Fatalf("Failed to walk bucket, %v", err)
+		}
+
+		expected := len(fixture_paths) * FIXTURE_RECORDS
+
+		if len(r.records) != expected {
+			t.Fatalf("Expected %d records but got %d (decode: %t)", expected, len(r.records), decode)
+		}
+
+		if !isSorted(r.records) {
+			t.Fatalf("Expected records to be sorted by path and line number (decode: %t)", decode)
+		}
+	}
+}
+
+func TestWalkBucketFailurePolicies(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, false)
+	defer cleanup()
+
+	total := len(fixture_paths) * FIXTURE_RECORDS
+	even := len(fixture_paths) * (FIXTURE_RECORDS / 2)
+
+	tests := []struct {
+		policy     string
+		max_errors int
+		cancelled  bool
+		min_errors int
+		max_seen   int
+	}{
+		{FAILURE_POLICY_SKIP, 0, false, even, total},
+		// Records are dispatched sequentially so the walk stops at the 1st (or 3rd) even record
+		{FAILURE_POLICY_FAIL_FAST, 0, true, 1, 2},
+		{FAILURE_POLICY_MAX_ERRORS, 3, true, 3, FIXTURE_RECORDS + 2},
+	}
+
+	for _, test := range tests {
+
+		r := newRecorder()
+
+		// Fail every other record
+
+		cb := func(ctx context.Context, rec *jw.WalkRecord, err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			r.add(rec.Path, rec.LineNumber)
+
+			if rec.LineNumber%2 == 0 {
+				return fmt.Errorf("Record %d is even", rec.LineNumber)
+			}
+
+			return nil
+		}
+
+		opts := &WalkOptions{
+			URI:           "metadata/edan/",
+			Workers:       1,
+			Ordered:       true,
+			Callback:      cb,
+			FailurePolicy: test.policy,
+			MaxErrors:     test.max_errors,
+		}
+
+		err := WalkBucket(ctx, opts, bucket)
+
+		if err == nil {
+			t.Fatalf("Expected walk with policy '%s' to fail", test.policy)
+		}
+
+		var walk_errs *WalkErrors
+
+		if !errors.As(err, &walk_errs) {
+			t.Fatalf("Expected *WalkErrors for policy '%s' but got %T", test.policy, err)
+		}
+
+		if walk_errs.Cancelled != test.cancelled {
+			t.Fatalf("Expected cancelled to be %t for policy '%s'", test.cancelled, test.policy)
+		}
+
+		if test.policy == FAILURE_POLICY_SKIP && len(walk_errs.Errors) != even {
+			t.Fatalf("Expected %d errors for policy '%s' but got %d", even, test.policy, len(walk_errs.Errors))
+		}
+
+		if len(walk_errs.Errors) < test.min_errors {
+			t.Fatalf("Expected at least %d errors for policy '%s' but got %d", test.min_errors, test.policy, len(walk_errs.Errors))
+		}
+
+		if len(r.records) > test.max_seen {
+			t.Fatalf("Expected at most %d records for policy '%s' but got %d", test.max_seen, test.policy, len(r.records))
+		}
+
+		for _, e := range walk_errs.Errors {
+
+			if e.Path == "" || e.LineNumber%2 != 0 {
+				t.Fatalf("Unexpected error for policy '%s', %s", test.policy, e.String())
+			}
+		}
+	}
+}
+
+func TestWalkBucketDecodeError(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, true)
+	defer cleanup()
+
+	r := newRecorder()
+
+	opts := &WalkOptions{
+		URI:            "metadata/edan/",
+		Workers:        4,
+		RecordCallback: r.recordCallback,
+	}
+
+	err := WalkBucket(ctx, opts, bucket)
+
+	var walk_errs *WalkErrors
+
+	if !errors.As(err, &walk_errs) {
+		t.Fatalf("Expected *WalkErrors but got %v", err)
+	}
+
+	if len(walk_errs.Errors) != 1 {
+		t.Fatalf("Expected 1 error but got %d", len(walk_errs.Errors))
+	}
+
+	e := walk_errs.Errors[0]
+
+	if e.Path != BAD_FIXTURE_PATH || e.LineNumber != BAD_FIXTURE_LINE {
+		t.Fatalf("Expected error for %s line %d but got %s", BAD_FIXTURE_PATH, BAD_FIXTURE_LINE, e.String())
+	}
+
+	expected := (len(fixture_paths)+1)*FIXTURE_RECORDS - 1
+
+	if len(r.records) != expected {
+		t.Fatalf("Expected %d records but got %d", expected, len(r.records))
+	}
+}
+
+func TestWalkBucketCheckpoint(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, true)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory, %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	// The callback returns decoding errors in the first case and ignores them in the second. In
+	// both cases the file that produced the error should not be marked as complete
+
+	for i, ignore_errors := range []bool{false, true} {
+
+		for _, ordered := range []bool{false, true} {
+
+			cp, err := checkpoint.NewFileCheckpoint(ctx, filepath.Join(dir, fmt.Sprintf("checkpoint-%d-%t.txt", i, ordered)))
+
+			if err != nil {
+				t.Fatalf("Failed to create checkpoint, %v", err)
+			}
+
+			r := newRecorder()
+
+			cb := func(ctx context.Context, rec *Record, err error) error {
+
+				if err != nil && ignore_errors {
+					return nil
+				}
+
+				return r.recordCallback(ctx, rec, err)
+			}
+
+			complete_cb := func(ctx context.Context, path string) error {
+
+				if path == BAD_FIXTURE_PATH {
+					return fmt.Errorf("Complete callback invoked for %s", path)
+				}
+
+				return nil
+			}
+
+			opts := &WalkOptions{
+				URI:              "metadata/edan/",
+				Workers:          4,
+				Ordered:          ordered,
+				RecordCallback:   cb,
+				Checkpoint:       cp,
+				CompleteCallback: complete_cb,
+			}
+
+			err = WalkBucket(ctx, opts, bucket)
+
+			if ignore_errors && err != nil {
+				t.Fatalf("Expected walk to succeed when errors are ignored, %v", err)
+			}
+
+			if !ignore_errors && err == nil {
+				t.Fatalf("Expected walk to fail")
+			}
+
+			if err != nil && strings.Contains(err.Error(), "Complete callback") {
+				t.Fatalf("Unexpected error, %v", err)
+			}
+
+			for _, path := range fixture_paths {
+
+				complete, _ := cp.IsComplete(ctx, path)
+
+				if !complete {
+					t.Fatalf("Expected %s to be complete (ignore errors: %t, ordered: %t)", path, ignore_errors, ordered)
+				}
+			}
+
+			complete, _ := cp.IsComplete(ctx, BAD_FIXTURE_PATH)
+
+			if complete {
+				t.Fatalf("Expected %s not to be complete (ignore errors: %t, ordered: %t)", BAD_FIXTURE_PATH, ignore_errors, ordered)
+			}
+
+			// Resuming only walks the file that failed
+
+			resume_r := newRecorder()
+
+			opts = &WalkOptions{
+				URI:            "metadata/edan/",
+				Workers:        4,
+				RecordCallback: resume_r.recordCallback,
+				Checkpoint:     cp,
+			}
+
+			WalkBucket(ctx, opts, bucket)
+
+			for _, rec := range resume_r.records {
+
+				if rec.path != BAD_FIXTURE_PATH {
+					t.Fatalf("Expected resumed walk to skip %s", rec.path)
+				}
+			}
+
+			cp.Close(ctx)
+		}
+	}
+}
+
+func TestWalkBucketSample(t *testing.T) {
+
+	ctx := context.Background()
+
+	bucket, cleanup := newFixtureBucket(t, false)
+	defer cleanup()
+
+	sample := func(seed int64, per_unit bool) []*walkedRecord {
+
+		r := newRecorder()
+
+		opts := &WalkOptions{
+			URI:           "metadata/edan/",
+			Workers:       4,
+			Callback:      r.callback,
+			SampleSize:    4,
+			SamplePerUnit: per_unit,
+			SampleSeed:    seed,
+		}
+
+		err := WalkBucket(ctx, opts, bucket)
+
+		if err != nil {
+			t.Fatalf("Failed to walk bucket, %v", err)
+		}
+
+		return r.records
+	}
+
+	key := func(records []*walkedRecord) string {
+
+		keys := make([]string, len(records))
+
+		for i, r := range records {
+			keys[i] = fmt.Sprintf("%s#%d", r.path, r.lineno)
+		}
+
+		return strings.Join(keys, ",")
+	}
+
+	first := sample(42, false)
+
+	if len(first) != 4 {
+		t.Fatalf("Expected 4 sampled records but got %d", len(first))
+	}
+
+	if !isSorted(first) {
+		t.Fatalf("Expected sampled records to be sorted by path and line number")
+	}
+
+	for i := 0; i < 5; i++ {
+
+		if key(sample(42, false)) != key(first) {
+			t.Fatalf("Expected the same seed to produce the same sample")
+		}
+	}
+
+	different := false
+
+	for seed := int64(1); seed < 10; seed++ {
+
+		if key(sample(seed, false)) != key(first) {
+			different = true
+			break
+		}
+	}
+
+	if !different {
+		t.Fatalf("Expected different seeds to produce different samples")
+	}
+
+	per_unit := sample(42, true)
+
+	counts := make(map[string]int)
+
+	for _, r := range per_unit {
+		counts[filepath.Base(filepath.Dir(r.path))] += 1
+	}
+
+	if counts["chndm"] != 4 || counts["nasm"] != 4 {
+		t.Fatalf("Expected 4 sampled records for each unit but got %v", counts)
+	}
+}